@@ -0,0 +1,150 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import "math"
+
+// binFractions returns the fraction of the binned samples of a and b in each
+// bin, panicking if a and b do not have identical bin layouts.
+func binFractions(method string, a, b *Stats) (fa, fb []float64) {
+	checkSameBins(method, a, b)
+	return a.smoothedBinFractions(0), b.smoothedBinFractions(0)
+}
+
+// checkSameBins panics if a and b have not both been binned with identical
+// bins, so the named method cannot compare them bin by bin.
+func checkSameBins(method string, a, b *Stats) {
+	a.checkBins(method)
+	b.checkBins(method)
+	if len(a.bins) != len(b.bins) || a.interval != b.interval {
+		panic(method + "() requires identical bins")
+	}
+	for i := range a.bins {
+		if a.bins[i] != b.bins[i] {
+			panic(method + "() requires identical bins")
+		}
+	}
+}
+
+// smoothedBinFractions returns the fraction of the binned samples in each
+// bin after adding pseudo to every bin count. Without smoothing the
+// fractions are NaN if there are no binned samples.
+func (s Stats) smoothedBinFractions(pseudo float64) []float64 {
+	total := 0
+	for _, c := range s.binCounts {
+		total += c
+	}
+	denom := float64(total) + pseudo*float64(len(s.binCounts))
+	f := make([]float64, len(s.binCounts))
+	for i, c := range s.binCounts {
+		f[i] = (float64(c) + pseudo) / denom
+	}
+	return f
+}
+
+// HistogramIntersection returns the similarity of the histograms of a and b,
+// the sum over the bins of the smaller of the fractions of a's and b's samples
+// in each bin. It is 1 for histograms of the same shape and 0 for histograms
+// with no bins in common, and NaN if either has no binned samples.
+//
+// a and b must have been binned with identical bins.
+func HistogramIntersection(a, b *Stats) float64 {
+	fa, fb := binFractions("HistogramIntersection", a, b)
+	var sum float64
+	for i := range fa {
+		sum += math.Min(fa[i], fb[i])
+	}
+	return sum
+}
+
+// HellingerDistance returns the Hellinger distance between the histograms of
+// a and b,
+//
+//	sqrt(1 - Σ sqrt(p[i]·q[i]))
+//
+// where p[i] and q[i] are the fractions of a's and b's samples in bin i and
+// the sum, the Bhattacharyya coefficient, measures their overlap. It lies in
+// [0,1]: 0 for histograms of the same shape and 1 for histograms with no bins
+// in common. It is NaN if either has no binned samples.
+//
+// a and b must have been binned with identical bins.
+func HellingerDistance(a, b *Stats) float64 {
+	p, q := binFractions("HellingerDistance", a, b)
+	var bc float64
+	for i := range p {
+		bc += math.Sqrt(p[i] * q[i])
+	}
+	// rounding can take the coefficient slightly above 1
+	return math.Sqrt(math.Max(0, 1-bc))
+}
+
+// KLDivergence returns the Kullback-Leibler divergence of the histogram of p
+// from that of q, in nats,
+//
+//	Σ P[i]·ln(P[i]/Q[i])
+//
+// where P[i] and Q[i] are the fractions of p's and q's samples in bin i. It
+// measures the information lost when q, typically a baseline, is used to
+// approximate p, and is not symmetric. It is 0 for histograms of the same
+// shape.
+//
+// A bin which is empty in q but not in p would make the divergence infinite,
+// so half a sample is added to every bin of both histograms before the
+// fractions are computed: P[i] = (count[i]+0.5)/(total+0.5·nbins). This keeps
+// the result finite, even when either histogram is empty, at the cost of
+// understating the divergence of histograms with few samples.
+//
+// p and q must have been binned with identical bins.
+func KLDivergence(p, q *Stats) float64 {
+	checkSameBins("KLDivergence", p, q)
+	fp, fq := p.smoothedBinFractions(0.5), q.smoothedBinFractions(0.5)
+	var kl float64
+	for i := range fp {
+		kl += fp[i] * math.Log(fp[i]/fq[i])
+	}
+	return kl
+}
+
+// Wasserstein1 returns the Wasserstein-1, or earth mover's, distance between
+// the distributions of the samples of a and b: the integral over x of
+// |Fa(x) - Fb(x)|, where Fa and Fb are their empirical CDFs. It is the least
+// total distance the probability mass of one must be moved to turn it into
+// the other, so unlike bin-overlap measures it grows smoothly with a shift in
+// location: two sets of samples differing only by a shift d are d apart. It is
+// NaN if either has no samples.
+//
+// The samples of both are sorted, so neither may have had CreateBins called.
+func Wasserstein1(a, b *Stats) float64 {
+	a.checkSamples("Wasserstein1")
+	b.checkSamples("Wasserstein1")
+	na, nb := len(a.samples), len(b.samples)
+	if na == 0 || nb == 0 {
+		return math.NaN()
+	}
+	a.sortSamples()
+	b.sortSamples()
+	var dist float64
+	i, j := 0, 0
+	prev := math.Min(float64(a.samples[0]), float64(b.samples[0]))
+	for i < na || j < nb {
+		x := math.Inf(1)
+		if i < na {
+			x = float64(a.samples[i])
+		}
+		if j < nb && float64(b.samples[j]) < x {
+			x = float64(b.samples[j])
+		}
+		// the CDFs are constant on [prev, x)
+		dist += math.Abs(float64(i)/float64(na)-float64(j)/float64(nb)) * (x - prev)
+		for i < na && float64(a.samples[i]) == x {
+			i++
+		}
+		for j < nb && float64(b.samples[j]) == x {
+			j++
+		}
+		prev = x
+	}
+	return dist
+}
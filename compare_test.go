@@ -0,0 +1,124 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"math"
+	"testing"
+)
+
+// binnedStats returns a new Stats with 10 bins over [0,8] holding samples.
+func binnedStats(samples ...Sample) *Stats {
+	s := NewStats()
+	s.CreateBins(10, 0, 8)
+	insertSamples(s, samples)
+	return s
+}
+
+func TestHistogramIntersection(t *testing.T) {
+	a := binnedStats(0.5, 1.5, 1.5, 2.5)
+	for i, test := range []struct {
+		b   *Stats
+		exp float64
+	}{
+		{binnedStats(0.5, 1.5, 1.5, 2.5), 1},
+		// the same shape with twice as many samples
+		{binnedStats(0.5, 0.5, 1.5, 1.5, 1.5, 1.5, 2.5, 2.5), 1},
+		{binnedStats(5.5, 6.5, 7.5), 0},
+		{binnedStats(1.5, 5.5), 0.5},
+	} {
+		if got := HistogramIntersection(a, test.b); math.Abs(got-test.exp) > 1e-12 {
+			t.Errorf("[%d] HistogramIntersection() = %v, expected %v", i, got, test.exp)
+		}
+	}
+	other := NewStats()
+	other.CreateBins(10, 0, 9)
+	expectPanic(t, "HistogramIntersection with different bins", func() { HistogramIntersection(a, other) })
+	expectPanic(t, "HistogramIntersection without bins", func() { HistogramIntersection(a, NewStats()) })
+}
+
+func TestHellingerDistance(t *testing.T) {
+	a := binnedStats(0.5, 1.5, 1.5, 2.5)
+	if got := HellingerDistance(a, binnedStats(2.5, 1.5, 0.5, 1.5)); got != 0 {
+		t.Errorf("identical HellingerDistance() = %v, expected 0", got)
+	}
+	if got := HellingerDistance(a, binnedStats(5.5, 6.5)); got != 1 {
+		t.Errorf("disjoint HellingerDistance() = %v, expected 1", got)
+	}
+	// one sample in a hundred overlapping
+	b := binnedStats(2.5)
+	for i := 0; i < 99; i++ {
+		b.AddSample(6.5)
+	}
+	if got := HellingerDistance(a, b); got < 0.9 || got >= 1 {
+		t.Errorf("nearly disjoint HellingerDistance() = %v, expected just under 1", got)
+	}
+	// p = (1/2, 1/2), q = (1/2, 0, 1/2): coefficient 1/2
+	if got := HellingerDistance(binnedStats(0.5, 1.5), binnedStats(0.5, 2.5)); math.Abs(got-math.Sqrt(0.5)) > 1e-12 {
+		t.Errorf("HellingerDistance() = %v, expected %v", got, math.Sqrt(0.5))
+	}
+	other := NewStats()
+	other.CreateBins(11, 0, 8)
+	expectPanic(t, "HellingerDistance with different bins", func() { HellingerDistance(a, other) })
+}
+
+func TestKLDivergence(t *testing.T) {
+	p := binnedStats(0.5, 1.5, 1.5, 2.5)
+	if got := KLDivergence(p, binnedStats(1.5, 0.5, 2.5, 1.5)); got != 0 {
+		t.Errorf("identical KLDivergence() = %v, expected 0", got)
+	}
+	// q has no samples where p has most of its samples
+	q := binnedStats(5.5, 6.5)
+	got := KLDivergence(p, q)
+	if math.IsInf(got, 0) || math.IsNaN(got) || got <= 0 {
+		t.Errorf("KLDivergence() with empty q bins = %v, expected finite and positive", got)
+	}
+	if other := KLDivergence(p, binnedStats(1.5, 6.5)); other >= got {
+		t.Errorf("KLDivergence() from an overlapping q = %v, expected less than %v", other, got)
+	}
+	if got := KLDivergence(p, binnedStats()); math.IsInf(got, 0) || math.IsNaN(got) {
+		t.Errorf("KLDivergence() from an empty q = %v, expected finite", got)
+	}
+	expectPanic(t, "KLDivergence without bins", func() { KLDivergence(p, NewStats()) })
+}
+
+func TestWasserstein1(t *testing.T) {
+	a, b := NewStats(), NewStats()
+	for _, val := range []Sample{1, 4, 4, 9, 2.5, 7} {
+		a.AddSample(val)
+		b.AddSample(val + 3.25)
+	}
+	if got := Wasserstein1(a, b); math.Abs(got-3.25) > 1e-12 {
+		t.Errorf("shifted Wasserstein1() = %v, expected 3.25", got)
+	}
+	if got := Wasserstein1(b, a); math.Abs(got-3.25) > 1e-12 {
+		t.Errorf("reversed Wasserstein1() = %v, expected 3.25", got)
+	}
+	if got := Wasserstein1(a, a); got != 0 {
+		t.Errorf("Wasserstein1(a, a) = %v, expected 0", got)
+	}
+	// half the mass of {0, 0} moves by 2 to make {0, 2}, as does the
+	// whole of {1} moving by 1 either way
+	for _, test := range []struct {
+		a, b []Sample
+		exp  float64
+	}{
+		{[]Sample{0, 0}, []Sample{0, 2}, 1},
+		{[]Sample{1}, []Sample{0, 2}, 1},
+		{[]Sample{0, 1, 2, 3}, []Sample{3}, 1.5},
+	} {
+		a, b := NewStats(), NewStats()
+		insertSamples(a, test.a)
+		insertSamples(b, test.b)
+		if got := Wasserstein1(a, b); math.Abs(got-test.exp) > 1e-12 {
+			t.Errorf("Wasserstein1(%v, %v) = %v, expected %v", test.a, test.b, got, test.exp)
+		}
+	}
+	if got := Wasserstein1(a, NewStats()); !math.IsNaN(got) {
+		t.Errorf("Wasserstein1() with no samples = %v, expected NaN", got)
+	}
+	a.CreateBins(3, 0, 1)
+	expectPanic(t, "Wasserstein1 after CreateBins", func() { Wasserstein1(a, b) })
+}
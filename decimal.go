@@ -0,0 +1,122 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// A DecimalStats represents statistics about exact decimal samples, such as
+// monetary amounts, for which the rounding of float64 accumulation is
+// unacceptable. Every sample and accumulated value is an exact big.Rat.
+//
+// This is far slower than Stats: each sample is heap allocated and each
+// addition does arbitrary-precision arithmetic whose cost grows with the
+// size of the numerators and denominators involved. The samples are always
+// retained.
+type DecimalStats struct {
+	sum     big.Rat
+	sum2    big.Rat
+	min     *big.Rat
+	max     *big.Rat
+	samples []*big.Rat
+	sorted  bool
+}
+
+// NewDecimalStats returns a new DecimalStats
+func NewDecimalStats() *DecimalStats {
+	return &DecimalStats{}
+}
+
+// AddRat adds a copy of val as a sample.
+func (s *DecimalStats) AddRat(val *big.Rat) {
+	v := new(big.Rat).Set(val)
+	s.sum.Add(&s.sum, v)
+	s.sum2.Add(&s.sum2, new(big.Rat).Mul(v, v))
+	if s.min == nil || v.Cmp(s.min) < 0 {
+		s.min = v
+	}
+	if s.max == nil || v.Cmp(s.max) > 0 {
+		s.max = v
+	}
+	s.samples = append(s.samples, v)
+	s.sorted = false
+}
+
+// AddString adds the sample written in str, which may be a decimal such as
+// "12.34" or a fraction such as "1/3". It returns an error, and adds nothing,
+// if str cannot be parsed.
+func (s *DecimalStats) AddString(str string) error {
+	v, ok := new(big.Rat).SetString(str)
+	if !ok {
+		return fmt.Errorf("summstat: invalid decimal %q", str)
+	}
+	s.AddRat(v)
+	return nil
+}
+
+// Count returns the number of samples added.
+func (s DecimalStats) Count() int {
+	return len(s.samples)
+}
+
+// Sum returns the exact sum of the samples.
+func (s DecimalStats) Sum() *big.Rat {
+	return new(big.Rat).Set(&s.sum)
+}
+
+// Sum2 returns the exact sum of the squares of the samples.
+func (s DecimalStats) Sum2() *big.Rat {
+	return new(big.Rat).Set(&s.sum2)
+}
+
+// Min returns the smallest sample, or 0 if there are none.
+func (s DecimalStats) Min() *big.Rat {
+	if s.min == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Set(s.min)
+}
+
+// Max returns the largest sample, or 0 if there are none.
+func (s DecimalStats) Max() *big.Rat {
+	if s.max == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Set(s.max)
+}
+
+// Mean returns the exact mean of the samples, or 0 if there are none.
+func (s DecimalStats) Mean() *big.Rat {
+	if len(s.samples) == 0 {
+		return new(big.Rat)
+	}
+	n := new(big.Rat).SetInt64(int64(len(s.samples)))
+	return new(big.Rat).Quo(&s.sum, n)
+}
+
+// Percentile returns the sample at the given percentile, chosen by rank as
+// for Stats.Percentile. It returns 0 if there are no samples.
+func (s DecimalStats) Percentile(pct float64) *big.Rat {
+	if pct < 0 {
+		panic("pct too small")
+	}
+	if pct > 1 {
+		panic("pct too large")
+	}
+	if len(s.samples) == 0 {
+		return new(big.Rat)
+	}
+	if !s.sorted {
+		sort.Slice(s.samples, func(i, j int) bool {
+			return s.samples[i].Cmp(s.samples[j]) < 0
+		})
+		s.sorted = true
+	}
+	i := int(float64(len(s.samples)-1)*pct + 0.5)
+	return new(big.Rat).Set(s.samples[i])
+}
@@ -0,0 +1,59 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestDecimalStats(t *testing.T) {
+	s := NewDecimalStats()
+	var f float64
+	for i := 0; i < 10; i++ {
+		if err := s.AddString("0.1"); err != nil {
+			t.Fatal(err)
+		}
+		f += 0.1
+	}
+	// ten float64 0.1s do not add up to 1
+	if f == 1 {
+		t.Errorf("float64 sum unexpectedly exact")
+	}
+	if s.Sum().Cmp(big.NewRat(1, 1)) != 0 {
+		t.Errorf("Sum() = %v, expected 1", s.Sum().FloatString(20))
+	}
+	if s.Mean().Cmp(big.NewRat(1, 10)) != 0 {
+		t.Errorf("Mean() = %v, expected 0.1", s.Mean().FloatString(20))
+	}
+	if s.Sum2().Cmp(big.NewRat(1, 10)) != 0 {
+		t.Errorf("Sum2() = %v, expected 0.1", s.Sum2().FloatString(20))
+	}
+
+	s = NewDecimalStats()
+	for _, str := range []string{"3.30", "1/3", "-2.5", "10"} {
+		if err := s.AddString(str); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddString("abc"); err == nil {
+		t.Errorf("AddString(\"abc\") succeeded")
+	}
+	if s.Count() != 4 {
+		t.Errorf("Count() = %d, expected 4", s.Count())
+	}
+	if s.Min().Cmp(big.NewRat(-5, 2)) != 0 {
+		t.Errorf("Min() = %v, expected -2.5", s.Min())
+	}
+	if s.Max().Cmp(big.NewRat(10, 1)) != 0 {
+		t.Errorf("Max() = %v, expected 10", s.Max())
+	}
+	if got := s.Percentile(.5); got.Cmp(big.NewRat(33, 10)) != 0 {
+		t.Errorf("Percentile(.5) = %v, expected 3.3", got)
+	}
+	if got := s.Percentile(.3); got.Cmp(big.NewRat(1, 3)) != 0 {
+		t.Errorf("Percentile(.3) = %v, expected 1/3", got)
+	}
+}
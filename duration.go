@@ -0,0 +1,49 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import "time"
+
+// A DurationStats represents statistics about Samples which each held for a
+// duration, such as a CPU utilization sampled over varying intervals, so that
+// each sample is weighted by how long it held.
+type DurationStats struct {
+	total     time.Duration
+	samples   []Sample
+	durations []time.Duration
+}
+
+// NewDurationStats returns a new DurationStats
+func NewDurationStats() *DurationStats {
+	return &DurationStats{}
+}
+
+// Add adds a sample value which held for duration d.
+func (s *DurationStats) Add(val Sample, d time.Duration) {
+	if d < 0 {
+		panic("negative duration")
+	}
+	s.total += d
+	s.samples = append(s.samples, val)
+	s.durations = append(s.durations, d)
+}
+
+// TotalDuration returns the sum of the durations added.
+func (s DurationStats) TotalDuration() time.Duration {
+	return s.total
+}
+
+// FractionAbove returns the fraction of the total duration for which the
+// sample value was greater than threshold. It is NaN if the total duration is
+// 0.
+func (s DurationStats) FractionAbove(threshold Sample) float64 {
+	var above time.Duration
+	for i, val := range s.samples {
+		if val > threshold {
+			above += s.durations[i]
+		}
+	}
+	return float64(above) / float64(s.total)
+}
@@ -0,0 +1,30 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDurationStats(t *testing.T) {
+	s := NewDurationStats()
+	s.Add(10, time.Second)
+	s.Add(95, 8*time.Second)
+	s.Add(20, time.Second)
+	if s.TotalDuration() != 10*time.Second {
+		t.Errorf("TotalDuration() = %v, expected 10s", s.TotalDuration())
+	}
+	// one of three samples is high, but it held for most of the time
+	if got := s.FractionAbove(90); got != 0.8 {
+		t.Errorf("FractionAbove(90) = %v, expected 0.8", got)
+	}
+	if got := s.FractionAbove(15); got != 0.9 {
+		t.Errorf("FractionAbove(15) = %v, expected 0.9", got)
+	}
+	if got := s.FractionAbove(95); got != 0 {
+		t.Errorf("FractionAbove(95) = %v, expected 0", got)
+	}
+}
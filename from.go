@@ -0,0 +1,55 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// FromReader returns a new Stats populated with the whitespace-separated
+// numbers read from r. The input is parsed one token at a time, but the
+// returned Stats retains every sample, so memory use grows with the number of
+// values read, as for any Stats before CreateBins.
+//
+// If a token cannot be parsed as a number, the returned error identifies the
+// offending token and its position in the input.
+func FromReader(r io.Reader) (*Stats, error) {
+	s := NewStats()
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+	for n := 1; scanner.Scan(); n++ {
+		tok := scanner.Text()
+		val, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("summstat: token %d (%q): %v", n, tok, err)
+		}
+		s.AddSample(Sample(val))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// FromFloat64s returns a new Stats populated with vals.
+func FromFloat64s(vals []float64) *Stats {
+	s := NewStats()
+	for _, val := range vals {
+		s.AddSample(Sample(val))
+	}
+	return s
+}
+
+// FromInts returns a new Stats populated with vals.
+func FromInts(vals []int) *Stats {
+	s := NewStats()
+	for _, val := range vals {
+		s.AddSample(Sample(val))
+	}
+	return s
+}
@@ -0,0 +1,42 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromReader(t *testing.T) {
+	s, err := FromReader(strings.NewReader("1 2\n3\t4  5\n"))
+	if err != nil {
+		t.Fatalf("FromReader: %v", err)
+	}
+	if s.Count() != 5 {
+		t.Errorf("Count() = %d, expected 5", s.Count())
+	}
+	if s.Mean() != 3 {
+		t.Errorf("Mean() = %v, expected 3", s.Mean())
+	}
+
+	_, err = FromReader(strings.NewReader("1 2 three 4"))
+	if err == nil {
+		t.Fatal("FromReader did not fail on an invalid token")
+	}
+	if !strings.Contains(err.Error(), `"three"`) {
+		t.Errorf("error %q does not mention the invalid token", err)
+	}
+}
+
+func TestFromSlices(t *testing.T) {
+	s := FromFloat64s([]float64{1.5, 2.5, 3.5})
+	if s.Count() != 3 || s.Mean() != 2.5 {
+		t.Errorf("FromFloat64s: count %d, mean %v", s.Count(), s.Mean())
+	}
+	s = FromInts([]int{1, 2, 3, 6})
+	if s.Count() != 4 || s.Mean() != 3 {
+		t.Errorf("FromInts: count %d, mean %v", s.Count(), s.Mean())
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import "sort"
+
+// A GroupStats routes samples to a separate Stats for each key, such as one
+// per endpoint of a service.
+type GroupStats struct {
+	opts  []Option
+	stats map[string]*Stats
+}
+
+// NewGroupStats returns a new GroupStats. The Stats for each key are created
+// with the given options when the first sample for that key is added.
+func NewGroupStats(opts ...Option) *GroupStats {
+	return &GroupStats{
+		opts:  opts,
+		stats: make(map[string]*Stats),
+	}
+}
+
+// Add adds a sample value to the Stats for key.
+func (g *GroupStats) Add(key string, val Sample) {
+	s, ok := g.stats[key]
+	if !ok {
+		s = NewStats(g.opts...)
+		g.stats[key] = s
+	}
+	s.AddSample(val)
+}
+
+// Get returns the Stats for key, or nil if no samples have been added for it.
+func (g *GroupStats) Get(key string) *Stats {
+	return g.stats[key]
+}
+
+// Keys returns the keys samples have been added for, in sorted order.
+func (g *GroupStats) Keys() []string {
+	keys := make([]string, 0, len(g.stats))
+	for key := range g.stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,31 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import "testing"
+
+func TestGroupStats(t *testing.T) {
+	g := NewGroupStats()
+	g.Add("/b", 10)
+	g.Add("/a", 1)
+	g.Add("/b", 20)
+	g.Add("/a", 2)
+	g.Add("/a", 3)
+
+	keys := g.Keys()
+	if len(keys) != 2 || keys[0] != "/a" || keys[1] != "/b" {
+		t.Errorf("Keys() = %v, expected [/a /b]", keys)
+	}
+	a, b := g.Get("/a"), g.Get("/b")
+	if a.Count() != 3 || a.Mean() != 2 || a.Max() != 3 {
+		t.Errorf("/a: count %d, mean %v, max %v", a.Count(), a.Mean(), a.Max())
+	}
+	if b.Count() != 2 || b.Mean() != 15 || b.Min() != 10 {
+		t.Errorf("/b: count %d, mean %v, min %v", b.Count(), b.Mean(), b.Min())
+	}
+	if g.Get("/c") != nil {
+		t.Errorf("Get of an unknown key returned non-nil")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// formatBound formats a bin boundary, writing the ±math.MaxFloat64 bounds of
+// the edge bins as infinities.
+func formatBound(b Sample) string {
+	switch b {
+	case -math.MaxFloat64:
+		return "-Inf"
+	case math.MaxFloat64:
+		return "+Inf"
+	}
+	return strconv.FormatFloat(float64(b), 'g', -1, 64)
+}
+
+// WriteHistogramText writes a text bar chart of the bins to w, one line per
+// bin giving its range, its count and a bar of '#' characters. The bars are
+// scaled so that the largest bin's bar is width characters long.
+func (s Stats) WriteHistogramText(w io.Writer, width int) error {
+	s.checkBins("WriteHistogramText")
+	if width < 1 {
+		panic("width must be positive")
+	}
+	ranges := make([]string, len(s.bins))
+	maxRange, maxCount := 0, 0
+	for i := range s.bins {
+		count, low, high := s.Bin(i)
+		left, right := "(", "]"
+		if s.interval == LowerInclusive {
+			left, right = "[", ")"
+		}
+		// infinite ends are always open
+		if i == 0 {
+			left = "("
+		}
+		if i == len(s.bins)-1 {
+			right = ")"
+		}
+		ranges[i] = left + formatBound(low) + ", " + formatBound(high) + right
+		if len(ranges[i]) > maxRange {
+			maxRange = len(ranges[i])
+		}
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+	countWidth := len(strconv.Itoa(maxCount))
+	for i, r := range ranges {
+		count := s.binCounts[i]
+		bar := 0
+		if maxCount > 0 {
+			bar = (count*width + maxCount/2) / maxCount
+		}
+		line := fmt.Sprintf("%-*s %*d %s", maxRange, r, countWidth, count, strings.Repeat("#", bar))
+		if _, err := io.WriteString(w, strings.TrimRight(line, " ")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteOpenMetrics writes the bins to w as an OpenMetrics histogram metric
+// family with the given name, terminated by "# EOF". Each bin's upper bound
+// becomes the le label of a cumulative _bucket line, with the last bin as the
+// +Inf bucket. The _sum and _count lines report the sum and count of all
+// samples, so any added before CreateBins are included only in those and in
+// the +Inf bucket.
+//
+// The le convention requires UpperInclusive bins, so it panics for
+// LowerInclusive ones.
+func (s Stats) WriteOpenMetrics(w io.Writer, name string) error {
+	s.checkBins("WriteOpenMetrics")
+	if s.interval != UpperInclusive {
+		panic("OpenMetrics buckets require UpperInclusive bins")
+	}
+	if _, err := fmt.Fprintf(w, "# TYPE %s histogram\n", name); err != nil {
+		return err
+	}
+	cum := 0
+	for i := 0; i < len(s.bins)-1; i++ {
+		cum += s.binCounts[i]
+		le := strconv.FormatFloat(float64(s.bins[i]), 'g', -1, 64)
+		if _, err := fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, le, cum); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %s\n%s_count %d\n# EOF\n",
+		name, s.count,
+		name, strconv.FormatFloat(float64(s.sum), 'g', -1, 64),
+		name, s.count)
+	return err
+}
+
+// SamplesFromHistogram approximates the binned samples of stats by returning
+// binCount copies of each bin's midpoint, so that a histogram-only Stats can
+// be combined with one holding raw samples by adding them to it. The edge
+// bins are taken to end at the minimum and maximum sample values.
+//
+// Only the bin counts are reproduced exactly: the position of each sample
+// within its bin is lost, so statistics such as the mean are approximate.
+func SamplesFromHistogram(stats *Stats) []Sample {
+	stats.checkBins("SamplesFromHistogram")
+	var samples []Sample
+	for i, c := range stats.binCounts {
+		low, high := stats.binBounds(i)
+		mid := low + (high-low)/2
+		for j := 0; j < c; j++ {
+			samples = append(samples, mid)
+		}
+	}
+	return samples
+}
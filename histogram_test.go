@@ -0,0 +1,132 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteHistogramText(t *testing.T) {
+	s := NewStats()
+	s.CreateBins(4, 0, 2)
+	insertSamples(s, []Sample{-1, 0.5, 0.5, 1.5, 1.5, 1.5, 1.5})
+	var buf bytes.Buffer
+	if err := s.WriteHistogramText(&buf, 8); err != nil {
+		t.Fatalf("WriteHistogramText: %v", err)
+	}
+	exp := "" +
+		"(-Inf, 0] 1 ##\n" +
+		"(0, 1]    2 ####\n" +
+		"(1, 2]    4 ########\n" +
+		"(2, +Inf) 0\n"
+	if buf.String() != exp {
+		t.Errorf("WriteHistogramText wrote:\n%s\nexpected:\n%s", buf.String(), exp)
+	}
+	for i, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		count, _, _ := s.Bin(i)
+		if bars := strings.Count(line, "#"); bars != count*2 {
+			t.Errorf("bin %d has %d '#', expected %d", i, bars, count*2)
+		}
+	}
+}
+
+func TestWriteHistogramTextLowerInclusive(t *testing.T) {
+	s := NewStats()
+	s.CreateBinsInterval(3, 0, 1, LowerInclusive)
+	insertSamples(s, []Sample{0, 1})
+	var buf bytes.Buffer
+	if err := s.WriteHistogramText(&buf, 1); err != nil {
+		t.Fatalf("WriteHistogramText: %v", err)
+	}
+	exp := "" +
+		"(-Inf, 0) 0\n" +
+		"[0, 1)    1 #\n" +
+		"[1, +Inf) 1 #\n"
+	if buf.String() != exp {
+		t.Errorf("WriteHistogramText wrote:\n%s\nexpected:\n%s", buf.String(), exp)
+	}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	s := NewStats()
+	s.CreateBins(5, 0, 3)
+	insertSamples(s, []Sample{-1, 0.5, 1.5, 1.5, 2.5, 20})
+	var buf bytes.Buffer
+	if err := s.WriteOpenMetrics(&buf, "latency_seconds"); err != nil {
+		t.Fatalf("WriteOpenMetrics: %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "# TYPE latency_seconds histogram" {
+		t.Errorf("first line = %q", lines[0])
+	}
+	if lines[len(lines)-2] != "# EOF" || lines[len(lines)-1] != "" {
+		t.Errorf("output does not end with \"# EOF\\n\": %q", buf.String())
+	}
+	bucket := regexp.MustCompile(`^latency_seconds_bucket\{le="([^"]+)"\} (\d+)$`)
+	sample := regexp.MustCompile(`^latency_seconds_(sum|count) (\S+)$`)
+	prevLe, prevCount, infCount, count := -1.0, 0, -1, -1
+	for _, line := range lines[1 : len(lines)-2] {
+		if m := bucket.FindStringSubmatch(line); m != nil {
+			le, err := strconv.ParseFloat(m[1], 64)
+			if err != nil || le <= prevLe {
+				t.Errorf("invalid or unordered le in %q", line)
+			}
+			c, _ := strconv.Atoi(m[2])
+			if c < prevCount {
+				t.Errorf("bucket counts are not cumulative at %q", line)
+			}
+			prevLe, prevCount = le, c
+			if m[1] == "+Inf" {
+				infCount = c
+			}
+		} else if m := sample.FindStringSubmatch(line); m != nil {
+			if _, err := strconv.ParseFloat(m[2], 64); err != nil {
+				t.Errorf("invalid value in %q", line)
+			}
+			if m[1] == "count" {
+				count, _ = strconv.Atoi(m[2])
+			}
+		} else {
+			t.Errorf("invalid line %q", line)
+		}
+	}
+	if count != s.Count() || infCount != s.Count() {
+		t.Errorf("_count = %d and +Inf bucket = %d, expected %d", count, infCount, s.Count())
+	}
+	if !strings.Contains(buf.String(), "latency_seconds_bucket{le=\"2\"} 4\n") {
+		t.Errorf("missing le=2 bucket in:\n%s", buf.String())
+	}
+}
+
+func TestSamplesFromHistogram(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := NewStats()
+	s.CreateBins(22, 0, 100)
+	for i := 0; i < 10000; i++ {
+		s.AddSample(Sample(50 + 20*r.NormFloat64()))
+	}
+	samples := SamplesFromHistogram(s)
+	if len(samples) != s.Count() {
+		t.Fatalf("len(SamplesFromHistogram()) = %d, expected %d", len(samples), s.Count())
+	}
+	rebinned := NewStats()
+	rebinned.CreateBins(22, 0, 100)
+	insertSamples(rebinned, samples)
+	for i := 0; i < s.NBins(); i++ {
+		exp, _, _ := s.Bin(i)
+		if got, _, _ := rebinned.Bin(i); got != exp {
+			t.Errorf("bin %d count = %d, expected %d", i, got, exp)
+		}
+	}
+	if math.Abs(rebinned.Mean()-s.Mean()) > 0.5 {
+		t.Errorf("Mean() of expanded samples = %v, expected about %v", rebinned.Mean(), s.Mean())
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"math"
+	"math/bits"
+)
+
+// hllPrecision is the number of hash bits used to choose a register. With
+// m = 2^14 registers the relative standard error of the estimate is about
+// 1.04/sqrt(m), or 0.8%, for 16KB of registers.
+const hllPrecision = 14
+
+// A hyperLogLog estimates the number of distinct values added to it using a
+// fixed amount of memory.
+type hyperLogLog struct {
+	registers []uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{
+		registers: make([]uint8, 1<<hllPrecision),
+	}
+}
+
+// add records val.
+func (h *hyperLogLog) add(val Sample) {
+	if val == 0 {
+		// treat -0 and 0 as the same value
+		val = 0
+	}
+	x := mix64(math.Float64bits(float64(val)))
+	i := x >> (64 - hllPrecision)
+	// position of the first 1 bit in the remaining bits
+	rho := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rho > h.registers[i] {
+		h.registers[i] = rho
+	}
+}
+
+// estimate returns the estimated number of distinct values added.
+func (h *hyperLogLog) estimate() int {
+	m := float64(len(h.registers))
+	var sum float64
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		// small range correction: linear counting
+		e = m * math.Log(m/float64(zeros))
+	}
+	return int(e + 0.5)
+}
+
+func (h *hyperLogLog) clone() *hyperLogLog {
+	return &hyperLogLog{
+		registers: append([]uint8(nil), h.registers...),
+	}
+}
+
+// mix64 is the finalizer of the SplitMix64 generator, used to spread the bits
+// of sample values evenly for hashing.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,29 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistinctCountApprox(t *testing.T) {
+	const stderr = 1.04 / 128 // 1.04/sqrt(2^14)
+	for _, distinct := range []int{10, 1000, 100000} {
+		s := NewStats(TrackDistinct())
+		s.CreateBins(3, 0, 1)
+		// each value is added three times
+		for rep := 0; rep < 3; rep++ {
+			for i := 0; i < distinct; i++ {
+				s.AddSample(Sample(i) * 0.5)
+			}
+		}
+		got := s.DistinctCountApprox()
+		if err := math.Abs(float64(got-distinct)) / float64(distinct); err > 3*stderr {
+			t.Errorf("DistinctCountApprox() = %d, expected about %d", got, distinct)
+		}
+	}
+	expectPanic(t, "DistinctCountApprox without tracking", func() { NewStats().DistinctCountApprox() })
+}
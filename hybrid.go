@@ -0,0 +1,169 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// A HybridStats represents descriptive statistics about Samples which are
+// being added incrementally, using bounded memory.
+//
+// The count, mean, standard deviation, minimum and maximum are exact, as for
+// Stats. Percentiles are estimated from a uniform random sample of the added
+// samples (a reservoir) of fixed size, so their accuracy depends on that size
+// rather than on the number of samples added. The reservoir is kept sorted,
+// so percentile queries neither reorder it nor depend on earlier ones.
+type HybridStats struct {
+	count     int
+	sum       Sample
+	sum2      Sample
+	max       Sample
+	min       Sample
+	size      int
+	reservoir []Sample
+}
+
+// NewHybridStats returns a new HybridStats which estimates percentiles from a
+// reservoir of up to size samples.
+func NewHybridStats(size int) *HybridStats {
+	if size < 1 {
+		panic("reservoir size must be positive")
+	}
+	return &HybridStats{
+		max:  -math.MaxFloat64,
+		min:  math.MaxFloat64,
+		size: size,
+	}
+}
+
+// AddSample adds a sample value and updates the statistics.
+func (h *HybridStats) AddSample(val Sample) {
+	h.count++
+	h.sum += val
+	h.sum2 += val * val
+	if val > h.max {
+		h.max = val
+	}
+	if val < h.min {
+		h.min = val
+	}
+	if len(h.reservoir) < h.size {
+		h.reservoir = insertSorted(h.reservoir, val)
+	} else if i := rand.Intn(h.count); i < h.size {
+		// keep val with probability size/count, evicting a uniformly
+		// chosen sample
+		h.reservoir = insertSorted(append(h.reservoir[:i], h.reservoir[i+1:]...), val)
+	}
+}
+
+// insertSorted inserts val into the sorted samples, keeping them sorted.
+func insertSorted(samples []Sample, val Sample) []Sample {
+	i := sort.Search(len(samples), func(i int) bool { return samples[i] > val })
+	samples = append(samples, 0)
+	copy(samples[i+1:], samples[i:])
+	samples[i] = val
+	return samples
+}
+
+// Merge adds all the samples summarized by other, so that statistics
+// collected on separate shards can be combined. The exact statistics remain
+// exact. The merged reservoir draws from each shard's reservoir in proportion
+// to the number of samples the shard has seen.
+func (h *HybridStats) Merge(other *HybridStats) {
+	n1, n2 := h.count, other.count
+	h.count += other.count
+	h.sum += other.sum
+	h.sum2 += other.sum2
+	if other.max > h.max {
+		h.max = other.max
+	}
+	if other.min < h.min {
+		h.min = other.min
+	}
+
+	r1 := shuffled(h.reservoir)
+	r2 := shuffled(other.reservoir)
+	size := h.size
+	if len(r1)+len(r2) < size {
+		size = len(r1) + len(r2)
+	}
+	merged := make([]Sample, 0, size)
+	for len(merged) < size {
+		if len(r2) == 0 || len(r1) > 0 && rand.Intn(n1+n2) < n1 {
+			merged, r1 = append(merged, r1[0]), r1[1:]
+		} else {
+			merged, r2 = append(merged, r2[0]), r2[1:]
+		}
+	}
+	sort.Sort(sampleSlice(merged))
+	h.reservoir = merged
+}
+
+// AddStats adds the retained samples of s, so that shards which kept their
+// raw samples can be combined with ones which only kept a HybridStats. The
+// samples pass through the reservoir just as if they had been added one at a
+// time, so percentile estimates are no less accurate than if every shard had
+// used a HybridStats from the start, but no more accurate either: the exact
+// percentiles of s are not preserved. The count, mean and other exact
+// statistics remain exact.
+//
+// It may not be called after s.CreateBins, which discards the samples, unless
+// they were kept with CreateBinsKeepSamples.
+func (h *HybridStats) AddStats(s *Stats) {
+	s.checkSamples("AddStats")
+	for _, val := range s.samples {
+		h.AddSample(val)
+	}
+}
+
+// shuffled returns a copy of samples in random order.
+func shuffled(samples []Sample) []Sample {
+	s := make([]Sample, len(samples))
+	for i, j := range rand.Perm(len(samples)) {
+		s[i] = samples[j]
+	}
+	return s
+}
+
+// Count returns the number of samples added.
+func (h HybridStats) Count() int {
+	return h.count
+}
+
+// Min returns minimal sample value added.
+func (h HybridStats) Min() Sample {
+	if h.min > h.max {
+		return 0
+	}
+	return h.min
+}
+
+// Max returns the maximal sample value added.
+func (h HybridStats) Max() Sample {
+	if h.min > h.max {
+		return 0
+	}
+	return h.max
+}
+
+// Mean returns the mean of the samples.
+func (h HybridStats) Mean() float64 {
+	return float64(h.sum) / float64(h.count)
+}
+
+// Stddev returns the standard deviation of the samples.
+func (h HybridStats) Stddev() float64 {
+	m := h.Mean()
+	return math.Sqrt(float64(h.sum2)/float64(h.count) - m*m)
+}
+
+// Percentile returns an estimate of the sample value at the given
+// percentile, computed as by Stats.Percentile over the reservoir.
+func (h HybridStats) Percentile(pct float64) Sample {
+	return Stats{samples: h.reservoir, sorted: true}.Percentile(pct)
+}
@@ -0,0 +1,85 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestHybridStats(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := NewStats()
+	h := NewHybridStats(10000)
+	a, b := NewHybridStats(10000), NewHybridStats(10000)
+	for i := 0; i < 1000000; i++ {
+		val := Sample(r.NormFloat64())
+		s.AddSample(val)
+		h.AddSample(val)
+		if i%4 == 0 {
+			a.AddSample(val)
+		} else {
+			b.AddSample(val)
+		}
+	}
+	a.Merge(b)
+	p99 := s.Percentile(.99)
+	for _, test := range []struct {
+		name string
+		h    *HybridStats
+	}{
+		{"single", h},
+		{"merged", a},
+	} {
+		h := test.h
+		if h.Count() != s.Count() || h.Min() != s.Min() || h.Max() != s.Max() {
+			t.Errorf("%s: count %d, min %v, max %v, expected %d, %v, %v",
+				test.name, h.Count(), h.Min(), h.Max(), s.Count(), s.Min(), s.Max())
+		}
+		if math.Abs(h.Mean()-s.Mean()) > 1e-12 {
+			t.Errorf("%s: Mean() = %v, expected %v", test.name, h.Mean(), s.Mean())
+		}
+		if math.Abs(h.Stddev()-s.Stddev()) > 1e-12 {
+			t.Errorf("%s: Stddev() = %v, expected %v", test.name, h.Stddev(), s.Stddev())
+		}
+		if got := h.Percentile(.99); math.Abs(float64(got-p99)) > 0.15 {
+			t.Errorf("%s: Percentile(.99) = %v, expected about %v", test.name, got, p99)
+		}
+		if len(h.reservoir) != 10000 {
+			t.Errorf("%s: reservoir has %d samples, expected 10000", test.name, len(h.reservoir))
+		}
+		if !sort.IsSorted(sampleSlice(h.reservoir)) {
+			t.Errorf("%s: reservoir is not sorted", test.name)
+		}
+	}
+}
+
+func TestHybridStatsAddStats(t *testing.T) {
+	raw := NewStats()
+	h := NewHybridStats(5000)
+	all := NewStats()
+	for i := 0; i < 20000; i++ {
+		val := Sample(i)
+		all.AddSample(val)
+		if i%2 == 0 {
+			raw.AddSample(val)
+		} else {
+			h.AddSample(val)
+		}
+	}
+	h.AddStats(raw)
+	if h.Count() != 20000 || h.Min() != 0 || h.Max() != 19999 || h.Mean() != all.Mean() {
+		t.Errorf("count %d, min %v, max %v, mean %v, expected 20000, 0, 19999, %v",
+			h.Count(), h.Min(), h.Max(), h.Mean(), all.Mean())
+	}
+	p99 := all.Percentile(.99)
+	if got := h.Percentile(.99); math.Abs(float64(got-p99)) > 200 {
+		t.Errorf("Percentile(.99) = %v, expected about %v", got, p99)
+	}
+	raw.CreateBins(3, 0, 1)
+	expectPanic(t, "AddStats after CreateBins", func() { h.AddStats(raw) })
+}
@@ -0,0 +1,83 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import "math"
+
+// KDE returns the Gaussian kernel density estimate at the point at: the mean
+// over the samples x of the normal density with mean x and standard deviation
+// bandwidth, evaluated at at. Larger bandwidths give smoother estimates. It
+// returns 0 if there are no samples.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) KDE(at Sample, bandwidth float64) float64 {
+	s.checkSamples("KDE")
+	if bandwidth <= 0 {
+		panic("bandwidth must be positive")
+	}
+	if len(s.samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, val := range s.samples {
+		u := float64(at-val) / bandwidth
+		sum += math.Exp(-u * u / 2)
+	}
+	return sum / (float64(len(s.samples)) * bandwidth * math.Sqrt(2*math.Pi))
+}
+
+// KDEGrid evaluates KDE at n evenly spaced points, returning the points and
+// the density at each. The points run from 3 bandwidths below the minimum
+// sample to 3 bandwidths above the maximum, covering all but a negligible
+// fraction of the estimated density. Each point takes time proportional to
+// the number of samples.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) KDEGrid(n int, bandwidth float64) ([]Sample, []float64) {
+	s.checkSamples("KDEGrid")
+	if n < 2 {
+		panic("n must be at least 2")
+	}
+	if bandwidth <= 0 {
+		panic("bandwidth must be positive")
+	}
+	if len(s.samples) == 0 {
+		return nil, nil
+	}
+	low := float64(s.min) - 3*bandwidth
+	high := float64(s.max) + 3*bandwidth
+	points := make([]Sample, n)
+	density := make([]float64, n)
+	for i := range points {
+		points[i] = Sample(low + (high-low)*float64(i)/float64(n-1))
+		density[i] = s.KDE(points[i], bandwidth)
+	}
+	return points, density
+}
+
+// SilvermanBandwidth returns a bandwidth for KDE chosen by Silverman's rule of
+// thumb,
+//
+//	0.9 · min(σ, IQR/1.34) · n^(-1/5)
+//
+// where σ is Stddev(), IQR is Percentile(0.75)-Percentile(0.25) and n is the
+// number of samples. The rule is near optimal for roughly normal data, using
+// the smaller of the two measures of spread so that outliers and moderately
+// skewed or multimodal data are not oversmoothed. If the IQR is 0, σ alone is
+// used. At least 2 samples are required.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) SilvermanBandwidth() float64 {
+	s.checkSamples("SilvermanBandwidth")
+	n := len(s.samples)
+	if n < 2 {
+		panic("Not enough samples")
+	}
+	spread := s.Stddev()
+	if iqr := float64(s.Percentile(0.75)-s.Percentile(0.25)) / 1.34; iqr > 0 && iqr < spread {
+		spread = iqr
+	}
+	return 0.9 * spread * math.Pow(float64(n), -0.2)
+}
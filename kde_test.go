@@ -0,0 +1,69 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestKDE(t *testing.T) {
+	s := NewStats()
+	s.AddSample(0)
+	// a single sample gives the kernel itself
+	if got, exp := s.KDE(1, 2), math.Exp(-0.125)/(2*math.Sqrt(2*math.Pi)); math.Abs(got-exp) > 1e-15 {
+		t.Errorf("KDE(1, 2) = %v, expected %v", got, exp)
+	}
+
+	s = NewStats()
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		s.AddSample(Sample(rng.NormFloat64()))
+		s.AddSample(Sample(5 + rng.NormFloat64()))
+	}
+	for _, bandwidth := range []float64{0.1, 0.5, 2} {
+		points, density := s.KDEGrid(500, bandwidth)
+		if len(points) != 500 || len(density) != 500 {
+			t.Fatalf("KDEGrid(500, %v) returned %d points", bandwidth, len(points))
+		}
+		var area float64
+		for i := 1; i < len(points); i++ {
+			area += float64(points[i]-points[i-1]) * (density[i] + density[i-1]) / 2
+		}
+		if math.Abs(area-1) > 0.01 {
+			t.Errorf("KDEGrid(500, %v) integrates to %v, expected 1", bandwidth, area)
+		}
+	}
+	// the two modes stand out from the trough between them
+	if s.KDE(0, 0.5) < 2*s.KDE(2.5, 0.5) || s.KDE(5, 0.5) < 2*s.KDE(2.5, 0.5) {
+		t.Errorf("KDE does not show modes at 0 and 5")
+	}
+	expectPanic(t, "KDE with bandwidth 0", func() { s.KDE(0, 0) })
+	s.CreateBins(3, 0, 1)
+	expectPanic(t, "KDE after CreateBins", func() { s.KDE(0, 1) })
+}
+
+func TestSilvermanBandwidth(t *testing.T) {
+	s := NewStats()
+	for i := 1; i <= 10; i++ {
+		s.AddSample(Sample(i))
+	}
+	// σ = sqrt(8.25) and IQR = 8-3 = 5, so σ < IQR/1.34
+	exp := 0.9 * math.Sqrt(8.25) * math.Pow(10, -0.2)
+	if got := s.SilvermanBandwidth(); math.Abs(got-exp) > 1e-12 {
+		t.Errorf("SilvermanBandwidth() = %v, expected %v", got, exp)
+	}
+	// an outlier inflates σ but not the IQR
+	s.AddSample(1000)
+	// with 11 samples the quartiles are at ranks 3 and 8, 4 and 9
+	exp = 0.9 * (5 / 1.34) * math.Pow(11, -0.2)
+	if got := s.SilvermanBandwidth(); math.Abs(got-exp) > 1e-12 {
+		t.Errorf("SilvermanBandwidth() with outlier = %v, expected %v", got, exp)
+	}
+	s = NewStats()
+	s.AddSample(1)
+	expectPanic(t, "SilvermanBandwidth with 1 sample", func() { s.SilvermanBandwidth() })
+}
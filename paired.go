@@ -0,0 +1,70 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import "math"
+
+// A PairedStats represents descriptive statistics about pairs of Samples,
+// such as their covariance and correlation, which are being added
+// incrementally.
+type PairedStats struct {
+	count int
+	sumX  float64
+	sumY  float64
+	sumX2 float64
+	sumY2 float64
+	sumXY float64
+}
+
+// NewPairedStats returns a new PairedStats
+func NewPairedStats() *PairedStats {
+	return &PairedStats{}
+}
+
+// AddPair adds a pair of sample values and updates the statistics.
+func (p *PairedStats) AddPair(x, y Sample) {
+	p.count++
+	p.sumX += float64(x)
+	p.sumY += float64(y)
+	p.sumX2 += float64(x * x)
+	p.sumY2 += float64(y * y)
+	p.sumXY += float64(x * y)
+}
+
+// Merge adds all the pairs from other, so that statistics collected on
+// separate shards can be combined.
+//
+// Every statistic is derived from the count and the raw sums of x, y, x², y²
+// and xy over all pairs. Each of those sums over the union of two shards is
+// the sum of the per-shard sums, so merging simply adds them; the cross term
+// Σxy in particular needs no correction for the differing shard means, which
+// only enter when the covariance is computed as Σxy/n - (Σx/n)(Σy/n).
+func (p *PairedStats) Merge(other *PairedStats) {
+	p.count += other.count
+	p.sumX += other.sumX
+	p.sumY += other.sumY
+	p.sumX2 += other.sumX2
+	p.sumY2 += other.sumY2
+	p.sumXY += other.sumXY
+}
+
+// Count returns the number of pairs added.
+func (p PairedStats) Count() int {
+	return p.count
+}
+
+// Covariance returns the covariance of the pairs.
+func (p PairedStats) Covariance() float64 {
+	n := float64(p.count)
+	return p.sumXY/n - (p.sumX/n)*(p.sumY/n)
+}
+
+// Correlation returns the Pearson correlation coefficient of the pairs.
+func (p PairedStats) Correlation() float64 {
+	n := float64(p.count)
+	varX := p.sumX2/n - (p.sumX/n)*(p.sumX/n)
+	varY := p.sumY2/n - (p.sumY/n)*(p.sumY/n)
+	return p.Covariance() / math.Sqrt(varX*varY)
+}
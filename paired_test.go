@@ -0,0 +1,51 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPairedStats(t *testing.T) {
+	p := NewPairedStats()
+	p.AddPair(1, 2)
+	p.AddPair(2, 4)
+	p.AddPair(3, 6)
+	if p.Count() != 3 {
+		t.Errorf("Count() = %d, expected 3", p.Count())
+	}
+	if math.Abs(p.Covariance()-4.0/3) > 1e-12 {
+		t.Errorf("Covariance() = %v, expected %v", p.Covariance(), 4.0/3)
+	}
+	if math.Abs(p.Correlation()-1) > 1e-12 {
+		t.Errorf("Correlation() = %v, expected 1", p.Correlation())
+	}
+}
+
+func TestPairedStatsMerge(t *testing.T) {
+	xs := []Sample{1, 2, 3, 4, 5, 6, 7, 8}
+	ys := []Sample{2, 1, 4, 3, 7, 5, 8, 9}
+	all := NewPairedStats()
+	a, b := NewPairedStats(), NewPairedStats()
+	for i := range xs {
+		all.AddPair(xs[i], ys[i])
+		if i < 3 {
+			a.AddPair(xs[i], ys[i])
+		} else {
+			b.AddPair(xs[i], ys[i])
+		}
+	}
+	a.Merge(b)
+	if a.Count() != all.Count() {
+		t.Errorf("merged Count() = %d, expected %d", a.Count(), all.Count())
+	}
+	if math.Abs(a.Covariance()-all.Covariance()) > 1e-12 {
+		t.Errorf("merged Covariance() = %v, expected %v", a.Covariance(), all.Covariance())
+	}
+	if math.Abs(a.Correlation()-all.Correlation()) > 1e-12 {
+		t.Errorf("merged Correlation() = %v, expected %v", a.Correlation(), all.Correlation())
+	}
+}
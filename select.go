@@ -0,0 +1,57 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import "sort"
+
+// selectNth partially orders data so that the element at index k is the one
+// that would be there if data were sorted, all elements before it are less
+// than or equal to it and all elements after it are greater than or equal to
+// it. This takes linear time on average rather than the O(n log n) of a full
+// sort.
+func selectNth(data sort.Interface, k int) {
+	lo, hi := 0, data.Len()-1
+	for lo < hi {
+		medianOfThree(data, lo, lo+(hi-lo)/2, hi)
+		// Three-way partition around the pivot at lo:
+		// [lo,lt) < pivot, [lt,gt] == pivot, (gt,hi] > pivot
+		lt, gt, i := lo, hi, lo+1
+		for i <= gt {
+			switch {
+			case data.Less(i, lt):
+				data.Swap(lt, i)
+				lt++
+				i++
+			case data.Less(lt, i):
+				data.Swap(i, gt)
+				gt--
+			default:
+				i++
+			}
+		}
+		switch {
+		case k < lt:
+			hi = lt - 1
+		case k > gt:
+			lo = gt + 1
+		default:
+			return
+		}
+	}
+}
+
+// medianOfThree moves the median of the elements at a, b and c to a.
+func medianOfThree(data sort.Interface, a, b, c int) {
+	if data.Less(b, a) {
+		data.Swap(a, b)
+	}
+	if data.Less(c, b) {
+		data.Swap(b, c)
+		if data.Less(b, a) {
+			data.Swap(a, b)
+		}
+	}
+	data.Swap(a, b)
+}
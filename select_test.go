@@ -0,0 +1,99 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSelectNth(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 3, 10, 101, 1000} {
+		orig := make([]Sample, n)
+		for i := range orig {
+			// a small range of values exercises duplicates
+			orig[i] = Sample(r.Intn(n/2 + 1))
+		}
+		sorted := make([]Sample, n)
+		copy(sorted, orig)
+		sort.Sort(sampleSlice(sorted))
+		for k := 0; k < n; k++ {
+			data := make([]Sample, n)
+			copy(data, orig)
+			selectNth(sampleSlice(data), k)
+			if data[k] != sorted[k] {
+				t.Fatalf("n=%d: selectNth(%d) = %v, expected %v", n, k, data[k], sorted[k])
+			}
+			for i := 0; i < k; i++ {
+				if data[i] > data[k] {
+					t.Fatalf("n=%d k=%d: data[%d] = %v > %v", n, k, i, data[i], data[k])
+				}
+			}
+			for i := k + 1; i < n; i++ {
+				if data[i] < data[k] {
+					t.Fatalf("n=%d k=%d: data[%d] = %v < %v", n, k, i, data[i], data[k])
+				}
+			}
+		}
+	}
+}
+
+const benchSamples = 1000000
+
+func randomSamples(n int) []Sample {
+	r := rand.New(rand.NewSource(1))
+	samples := make([]Sample, n)
+	for i := range samples {
+		samples[i] = Sample(r.Float64())
+	}
+	return samples
+}
+
+// BenchmarkPercentileSelect measures a single Percentile query on unsorted
+// samples, which uses selectNth.
+func BenchmarkPercentileSelect(b *testing.B) {
+	orig := randomSamples(benchSamples)
+	s := NewStats()
+	insertSamples(s, orig)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		copy(s.samples, orig)
+		b.StartTimer()
+		s.Percentile(.99)
+	}
+}
+
+// BenchmarkPercentileSort measures the same query answered by fully sorting
+// the samples.
+func BenchmarkPercentileSort(b *testing.B) {
+	orig := randomSamples(benchSamples)
+	s := NewStats()
+	insertSamples(s, orig)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		copy(s.samples, orig)
+		b.StartTimer()
+		s.sortSamples()
+		_ = s.samples[s.rankIndex(.99)]
+		s.sorted = false
+	}
+}
+
+// BenchmarkPercentileOrdered measures a Percentile query on samples which
+// were added in ascending order, so need neither sorting nor selection.
+func BenchmarkPercentileOrdered(b *testing.B) {
+	s := NewStats()
+	for i := 0; i < benchSamples; i++ {
+		s.AddSample(Sample(i))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Percentile(.99)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package summstat
+
+import "iter"
+
+// SortedSeq returns an iterator over the samples in ascending order. The
+// samples are sorted in place when SortedSeq is called, unless they already
+// are, and are then yielded without being copied, so ranging over the
+// iterator is cheap and may stop early. Adding samples while iterating
+// invalidates the iterator.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s *Stats) SortedSeq() iter.Seq[Sample] {
+	s.checkSamples("SortedSeq")
+	s.sortSamples()
+	samples := s.samples
+	return func(yield func(Sample) bool) {
+		for _, val := range samples {
+			if !yield(val) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package summstat
+
+import "testing"
+
+func TestSortedSeq(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{5, 3, 9, 1, 7, 2})
+	var got []Sample
+	for val := range s.SortedSeq() {
+		if val > 3 {
+			break
+		}
+		got = append(got, val)
+	}
+	exp := []Sample{1, 2, 3}
+	if len(got) != len(exp) {
+		t.Fatalf("SortedSeq() yielded %v before break, expected %v", got, exp)
+	}
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Errorf("SortedSeq() value %d = %v, expected %v", i, got[i], exp[i])
+		}
+	}
+	if !s.sorted {
+		t.Errorf("sort not cached by SortedSeq()")
+	}
+	n := 0
+	for range s.SortedSeq() {
+		n++
+	}
+	if n != 6 {
+		t.Errorf("SortedSeq() yielded %d values, expected 6", n)
+	}
+	s.CreateBins(3, 0, 1)
+	expectPanic(t, "SortedSeq after CreateBins", func() { s.SortedSeq() })
+}
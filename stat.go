@@ -6,6 +6,8 @@ package summstat
 
 import (
 	"math"
+	"math/cmplx"
+	"math/rand"
 	"sort"
 	"time"
 )
@@ -28,50 +30,226 @@ func (s sampleSlice) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
 }
 
+// Sorts samples along with their insertion indices
+type indexedSamples struct {
+	samples []Sample
+	indices []int
+}
+
+func (s indexedSamples) Len() int {
+	return len(s.samples)
+}
+
+func (s indexedSamples) Less(i, j int) bool {
+	return s.samples[i] < s.samples[j]
+}
+
+func (s indexedSamples) Swap(i, j int) {
+	s.samples[i], s.samples[j] = s.samples[j], s.samples[i]
+	s.indices[i], s.indices[j] = s.indices[j], s.indices[i]
+}
+
 // A Stats represents descriptive statistics about Samples which are being
 // added incrementally.
 type Stats struct {
 	count     int
+	first     Sample
+	last      Sample
 	sum       Sample
 	sum2      Sample
 	max       Sample
 	min       Sample
+	max2      Sample
+	min2      Sample
 	samples   []Sample
-	sorted    bool
+	sorted    bool // maintained by AddSample, so ordered input is never sorted
 	bins      []Sample
 	binCounts []int
+	binSums   []Sample
+	interval  BinInterval
+	// keepSamples is set by CreateBinsKeepSamples
+	keepSamples bool
+	extrapolate bool
+
+	rounding RankRounding
+	unbiased bool
+
+	trackLogRecip bool
+	sumLog        float64
+	sumRecip      float64
+
+	deltas   *Stats
+	distinct *hyperLogLog
+
+	// indices holds the insertion index of each retained sample
+	trackIndices bool
+	indices      []int
+}
+
+// A RankRounding determines how Percentile converts the fractional rank
+// pct*(Count()-1) into the index of a sample.
+type RankRounding int
+
+const (
+	// RoundHalfUp rounds to the nearest index, rounding halves up: a rank
+	// of 2.5 selects index 3 and 2.2 selects 2. This is the default.
+	RoundHalfUp RankRounding = iota
+	// Floor rounds down: ranks of 2.5 and 2.8 both select index 2.
+	Floor
+	// Ceil rounds up: ranks of 2.2 and 2.5 both select index 3.
+	Ceil
+	// RoundHalfEven rounds to the nearest index, rounding halves to the
+	// even index: a rank of 2.5 selects index 2 and 3.5 selects 4.
+	RoundHalfEven
+)
+
+// SetRankRounding sets how percentile ranks are rounded to sample indices.
+func (s *Stats) SetRankRounding(mode RankRounding) {
+	s.rounding = mode
+}
+
+// SetEdgeExtrapolation sets whether BinnedPercentile extrapolates into the
+// edge bins rather than clamping percentiles falling in them to the binned
+// range. When on, the edge bins are taken to end at the minimum and maximum
+// sample values, which are tracked exactly, and their samples to be spread
+// evenly between those and the range given to CreateBins. This estimates the
+// extreme tails much better than clamping, but can overshoot when a single
+// outlier stretches an edge bin.
+func (s *Stats) SetEdgeExtrapolation(on bool) {
+	s.extrapolate = on
+}
+
+// An Option configures optional behaviour of a Stats created by NewStats.
+type Option func(*Stats)
+
+// TrackLogReciprocal enables tracking of the sums of the logarithms and the
+// reciprocals of the samples, which are needed by GeometricMean and
+// HarmonicMean.
+//
+// This is off by default because it costs a logarithm and a division for
+// every sample added, which users who don't need these means shouldn't pay.
+func TrackLogReciprocal() Option {
+	return func(s *Stats) {
+		s.trackLogRecip = true
+	}
+}
+
+// TrackDeltas enables collection of statistics about the differences between
+// consecutive samples, which are available from Deltas. This is useful for
+// measuring jitter.
+//
+// The deltas are retained as samples are, costing as much memory again. When
+// the Stats is binned the deltas are binned too, over the same number of bins
+// spanning ±(high-low), the range of differences between samples inside the
+// binned range, and their samples are discarded unless the Stats keeps its
+// own.
+func TrackDeltas() Option {
+	return func(s *Stats) {
+		s.deltas = NewStats()
+	}
+}
+
+// TrackDistinct enables estimation of the number of distinct sample values
+// with a HyperLogLog sketch, which DistinctCountApprox reports. Unlike
+// DistinctCount, this works after CreateBins has discarded the samples. The
+// sketch takes 16KB and the estimate has a relative standard error of about
+// 1.04/sqrt(16384), or 0.8%.
+func TrackDistinct() Option {
+	return func(s *Stats) {
+		s.distinct = newHyperLogLog()
+	}
+}
+
+// WithUnbiasedVariance makes Variance and Stddev, and so the methods built on
+// them, use the sample (N-1) denominator rather than the population (N)
+// denominator. This corrects the underestimate of the population variance
+// from small data sets. With a single sample the variance is then NaN.
+//
+// CentralMoment(2) always gives the population variance, for code which needs
+// it regardless of this option.
+func WithUnbiasedVariance() Option {
+	return func(s *Stats) {
+		s.unbiased = true
+	}
+}
+
+// TrackIndices enables tracking of the order in which the retained samples
+// were added, so that PercentileSample can report where a sample came from.
+// Methods such as Percentile reorder the samples in place, so methods that
+// depend on the order the samples were added, such as ZScores, require this
+// option. It costs an int of memory per retained sample.
+func TrackIndices() Option {
+	return func(s *Stats) {
+		s.trackIndices = true
+	}
 }
 
-// NewStats returns a new Stats
-func NewStats() *Stats {
-	return &Stats{
-		max: -math.MaxFloat64,
-		min: math.MaxFloat64,
+// NewStats returns a new Stats configured with the given options.
+func NewStats(opts ...Option) *Stats {
+	s := &Stats{
+		max:    -math.MaxFloat64,
+		min:    math.MaxFloat64,
+		max2:   -math.MaxFloat64,
+		min2:   math.MaxFloat64,
+		sorted: true,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // AddSample adds a sample value and updates the statistics.
 func (s *Stats) AddSample(val Sample) {
+	if s.deltas != nil && s.count > 0 {
+		s.deltas.AddSample(val - s.last)
+	}
 	s.count++
+	if s.count == 1 {
+		s.first = val
+	}
+	s.last = val
 	s.sum += val
 	s.sum2 += val * val
 	if val > s.max {
+		s.max2 = s.max
 		s.max = val
+	} else if val > s.max2 {
+		s.max2 = val
 	}
 	if val < s.min {
+		s.min2 = s.min
 		s.min = val
+	} else if val < s.min2 {
+		s.min2 = val
+	}
+	if s.distinct != nil {
+		s.distinct.add(val)
+	}
+	if s.trackLogRecip {
+		s.sumLog += math.Log(float64(val))
+		s.sumRecip += 1 / float64(val)
 	}
 	if len(s.bins) > 0 {
-		// TODO: use faster lookup method for large bin counts
-		for bin, binVal := range s.bins {
-			if val <= binVal {
-				s.binCounts[bin]++
-				break
-			}
+		s.binSample(val)
+	}
+	if len(s.bins) == 0 || s.keepSamples {
+		if n := len(s.samples); n > 0 && val < s.samples[n-1] {
+			s.sorted = false
 		}
-	} else {
 		s.samples = append(s.samples, val)
-		s.sorted = false
+		if s.trackIndices {
+			s.indices = append(s.indices, s.count-1)
+		}
+	}
+}
+
+// binSample counts val in the bin it falls in.
+func (s *Stats) binSample(val Sample) {
+	bin := s.binIndex(val)
+	s.binCounts[bin]++
+	if s.binSums != nil {
+		s.binSums[bin] += val
 	}
 }
 
@@ -80,6 +258,35 @@ func (s *Stats) AddSampleSince(t time.Time) {
 	s.AddSample(Sample(time.Since(t)))
 }
 
+// AddComplex adds the magnitude of c as a sample, so that all statistics,
+// including percentiles and the mean, describe the magnitudes. The phase of c
+// is discarded.
+func (s *Stats) AddComplex(c complex128) {
+	s.AddSample(Sample(cmplx.Abs(c)))
+}
+
+// ConsumeChannel adds the samples received from ch until ch is closed or done
+// is closed, and returns the number of samples added. A nil done never fires.
+//
+// It may be run in its own goroutine, but Stats is not safe for concurrent
+// use: s must not be read or otherwise modified until ConsumeChannel returns,
+// unless every access, including ConsumeChannel's, is guarded by a lock.
+func (s *Stats) ConsumeChannel(ch <-chan Sample, done <-chan struct{}) int {
+	n := 0
+	for {
+		select {
+		case val, ok := <-ch:
+			if !ok {
+				return n
+			}
+			s.AddSample(val)
+			n++
+		case <-done:
+			return n
+		}
+	}
+}
+
 // AddStats adds all the samples from stats.
 func (s *Stats) AddStats(stats *Stats) {
 	for _, val := range stats.samples {
@@ -87,11 +294,184 @@ func (s *Stats) AddStats(stats *Stats) {
 	}
 }
 
+// Compact reallocates the retained samples to fit exactly, releasing the
+// spare capacity left over from growing them as samples were added, which can
+// be nearly as large as the samples themselves. It is worth calling once a
+// large Stats has stopped growing but is to be kept, such as a snapshot of a
+// finished run; adding further samples afterwards will grow the storage
+// again.
+func (s *Stats) Compact() {
+	if cap(s.samples) > len(s.samples) {
+		samples := make([]Sample, len(s.samples))
+		copy(samples, s.samples)
+		s.samples = samples
+	}
+	if cap(s.indices) > len(s.indices) {
+		indices := make([]int, len(s.indices))
+		copy(indices, s.indices)
+		s.indices = indices
+	}
+}
+
+// Clone returns a deep copy of s. Samples subsequently added to either Stats
+// do not affect the other, including the bin counts of binned Stats, so Clone
+// can be used to snapshot a histogram while collection continues.
+func (s *Stats) Clone() *Stats {
+	c := *s
+	c.samples = append([]Sample(nil), s.samples...)
+	if s.indices != nil {
+		c.indices = append([]int(nil), s.indices...)
+	}
+	c.bins = append([]Sample(nil), s.bins...)
+	c.binCounts = append([]int(nil), s.binCounts...)
+	if s.binSums != nil {
+		c.binSums = append([]Sample(nil), s.binSums...)
+	}
+	if s.deltas != nil {
+		c.deltas = s.deltas.Clone()
+	}
+	if s.distinct != nil {
+		c.distinct = s.distinct.clone()
+	}
+	return &c
+}
+
+// newDerived returns a new Stats for samples derived from those of s, with the
+// same variance and percentile rank conventions.
+func (s Stats) newDerived() *Stats {
+	d := NewStats()
+	d.unbiased = s.unbiased
+	d.rounding = s.rounding
+	return d
+}
+
+// FilterStats returns a new Stats built from the samples for which pred
+// returns true.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) FilterStats(pred func(Sample) bool) *Stats {
+	s.checkSamples("FilterStats")
+	f := s.newDerived()
+	for _, val := range s.samples {
+		if pred(val) {
+			f.AddSample(val)
+		}
+	}
+	return f
+}
+
+// A State is a snapshot of the numerical state of a Stats, as returned by
+// Stats.State.
+type State struct {
+	Count      int
+	Sum        Sample
+	Sum2       Sample
+	Min        Sample
+	Max        Sample
+	Bins       []Sample // upper bounds of the bins, if any
+	BinCounts  []int
+	NumSamples int // number of retained samples
+}
+
+// State returns a snapshot of the internal state of s for inspection. Min and
+// Max are the raw tracked values, which are ±math.MaxFloat64 while there are
+// no samples. The slices are copies, so modifying them does not affect s.
+func (s Stats) State() State {
+	return State{
+		Count:      s.count,
+		Sum:        s.sum,
+		Sum2:       s.sum2,
+		Min:        s.min,
+		Max:        s.max,
+		Bins:       append([]Sample(nil), s.bins...),
+		BinCounts:  append([]int(nil), s.binCounts...),
+		NumSamples: len(s.samples),
+	}
+}
+
+// Apply folds fn over the samples, starting from init, and returns the
+// result. This allows custom statistics, such as the product of the samples,
+// to be computed without extracting them.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) Apply(fn func(acc, val Sample) Sample, init Sample) Sample {
+	s.checkSamples("Apply")
+	acc := init
+	for _, val := range s.samples {
+		acc = fn(acc, val)
+	}
+	return acc
+}
+
+// NonZeroStats returns a new Stats built from the samples which are not 0,
+// for sparse data in which zeros mean there was no activity. ZeroCount gives
+// the number of samples left out.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) NonZeroStats() *Stats {
+	s.checkSamples("NonZeroStats")
+	return s.FilterStats(func(val Sample) bool { return val != 0 })
+}
+
+// LogStats returns a new Stats built from the natural logarithms of the
+// samples, for log-normal data. The exponential of its Mean is the geometric
+// mean of the samples, and the exponential of its Stddev their geometric
+// standard deviation. It panics if any sample is not positive.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) LogStats() *Stats {
+	s.checkSamples("LogStats")
+	l := s.newDerived()
+	for _, val := range s.samples {
+		if !(val > 0) {
+			panic("LogStats() requires positive samples")
+		}
+		l.AddSample(Sample(math.Log(float64(val))))
+	}
+	return l
+}
+
+// ZeroCount returns the number of samples which are 0.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) ZeroCount() int {
+	s.checkSamples("ZeroCount")
+	n := 0
+	for _, val := range s.samples {
+		if val == 0 {
+			n++
+		}
+	}
+	return n
+}
+
 // Count returns the number of samples added.
 func (s Stats) Count() int {
 	return s.count
 }
 
+// First returns the first sample value added, or 0 if there are none.
+func (s Stats) First() Sample {
+	return s.first
+}
+
+// Last returns the most recent sample value added, or 0 if there are none.
+func (s Stats) Last() Sample {
+	return s.last
+}
+
+// Deltas returns the statistics of the differences between each sample and
+// the one added before it. The first sample produces no difference, so the
+// count of the deltas is one less than the count of the samples.
+//
+// It panics unless the Stats was created with the TrackDeltas option.
+func (s Stats) Deltas() *Stats {
+	if s.deltas == nil {
+		panic("Deltas() requires the TrackDeltas() option")
+	}
+	return s.deltas
+}
+
 // Min returns minimal sample value added.
 func (s Stats) Min() Sample {
 	if s.min > s.max {
@@ -108,141 +488,1759 @@ func (s Stats) Max() Sample {
 	return s.max
 }
 
+// SecondMin returns the second smallest sample value added, which equals Min
+// if the smallest value was added more than once. It returns 0 if fewer than
+// two samples have been added.
+func (s Stats) SecondMin() Sample {
+	if s.count < 2 {
+		return 0
+	}
+	return s.min2
+}
+
+// SecondMax returns the second largest sample value added, which equals Max
+// if the largest value was added more than once. It returns 0 if fewer than
+// two samples have been added.
+func (s Stats) SecondMax() Sample {
+	if s.count < 2 {
+		return 0
+	}
+	return s.max2
+}
+
+// sortable returns the samples as a sort.Interface, which keeps any tracked
+// insertion indices in step with the samples.
+func (s Stats) sortable() sort.Interface {
+	if s.trackIndices {
+		return indexedSamples{s.samples, s.indices}
+	}
+	return sampleSlice(s.samples)
+}
+
 func (s *Stats) sortSamples() {
 	if !s.sorted {
-		sort.Sort(sampleSlice(s.samples))
+		sort.Sort(s.sortable())
 		s.sorted = true
 	}
 }
 
+// checkSamples panics if the raw samples needed by the named method have been
+// discarded by CreateBins.
+func (s Stats) checkSamples(method string) {
+	if len(s.bins) > 0 && !s.keepSamples {
+		panic("cannot call " + method + "() after CreateBins()")
+	}
+}
+
 // Percentile returns the sample value at the given percentile.
 //
+// The sample returned is the one whose index in the sorted samples is nearest
+// to pct*(Count()-1); see SetRankRounding for other ways of rounding.
+//
+// If the samples were added in ascending order, or have since been sorted,
+// the sample is looked up directly. Otherwise they are partially ordered
+// around the requested rank in linear time rather than being fully sorted.
+//
+// NaN compares false against every value, so if any NaN samples have been
+// added the samples cannot be ordered consistently and the result is
+// unpredictable. Use PercentileIgnoreNaN in that case.
+//
 // It may not be called after CreateBins, which discards the samples from
 // which the percentile is calculated.
 func (s Stats) Percentile(pct float64) Sample {
-	if len(s.bins) > 0 {
-		panic("cannot call Percentile() after CreateBins()")
-	}
+	s.checkSamples("Percentile")
 	if len(s.samples) == 0 {
 		return 0
 	}
-	if pct < 0 {
-		panic("pct too small")
-	}
-	if pct > 1 {
-		panic("pct too large")
+	i := s.rankIndex(pct)
+	if !s.sorted {
+		// a single query only needs the element at one rank
+		selectNth(s.sortable(), i)
 	}
-	s.sortSamples()
-	// scale pct into int in [0, len-1]
-	// Adding 0.5 turns the implicit floor operation of int() into a rounding operation
-	i := int(float64(len(s.samples)-1)*pct + 0.5)
 	return s.samples[i]
 }
 
-// Median returns the median of the samples.
+// PercentileSample returns the same sample as Percentile along with the index
+// at which it was added, counting from 0, for finding a representative
+// example of the data. It returns (0, -1) if there are no samples.
 //
-// It may not be called after CreateBins, which discards the samples from
-// which the percentile is calculated.
-func (s Stats) Median() float64 {
-	if len(s.bins) > 0 {
-		panic("cannot call Percentile() after CreateBins()")
+// It panics unless the Stats was created with the TrackIndices option, and
+// may not be called after CreateBins, which discards the samples.
+func (s Stats) PercentileSample(pct float64) (val Sample, originalIndex int) {
+	s.checkSamples("PercentileSample")
+	if !s.trackIndices {
+		panic("PercentileSample() requires the TrackIndices() option")
 	}
-	l := len(s.samples)
-	if l == 0 {
-		return 0
+	if len(s.samples) == 0 {
+		return 0, -1
 	}
+	i := s.rankIndex(pct)
 	s.sortSamples()
-	half, rem := l/2, l%2
-	if rem == 0 {
-		return (float64(s.samples[half]) + float64(s.samples[half-1])) / 2
-	}
-	return float64(s.samples[half])
-}
-
-// Mean returns the mean of the samples.
-func (s Stats) Mean() float64 {
-	return float64(s.sum) / float64(s.count)
+	return s.samples[i], s.indices[i]
 }
 
-// Stddev returns the standard deviation of the samples.
-func (s Stats) Stddev() float64 {
-	m := s.Mean()
-	return math.Sqrt(float64(s.sum2)/float64(s.count) - m*m)
+// PercentileIgnoreNaN is like Percentile, but ignores any NaN samples.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) PercentileIgnoreNaN(pct float64) Sample {
+	s.checkSamples("PercentileIgnoreNaN")
+	finite := make([]Sample, 0, len(s.samples))
+	for _, val := range s.samples {
+		if !math.IsNaN(float64(val)) {
+			finite = append(finite, val)
+		}
+	}
+	return s.withSamples(finite).Percentile(pct)
 }
 
-// Spread returns the difference of the maximal and minimal sample values.
-func (s Stats) Spread() Sample {
-	if s.min > s.max {
+// PercentileStderr estimates the standard error of Percentile(pct) by
+// bootstrapping: the samples are resampled with replacement bootstraps times,
+// and the standard deviation of the percentiles of those resamples is
+// returned.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) PercentileStderr(pct float64, bootstraps int) float64 {
+	s.checkSamples("PercentileStderr")
+	if bootstraps < 2 {
+		panic("Not enough bootstraps")
+	}
+	n := len(s.samples)
+	if n == 0 {
 		return 0
 	}
-	return s.max - s.min
+	est := NewStats()
+	resample := make([]Sample, n)
+	for b := 0; b < bootstraps; b++ {
+		for i := range resample {
+			resample[i] = s.samples[rand.Intn(n)]
+		}
+		est.AddSample(s.withSamples(resample).Percentile(pct))
+	}
+	return est.Stddev()
 }
 
-// CreateBins divides the sample space into nbins bins for tracking counts.
-//
-// As samples are added, the count for the corresponding bin will be
-// incremented and the sample value will not be stored.
-//
-// This saves memory at the expense of granularity. Percentile() and Median()
-// cannot be called after CreateBins() because they are no longer meaningful.
-// Instead, use Bin(i) to inspect the distribution of data by bin. Any existing
-// stored samples are discarded.
+// Resample draws n values from the empirical distribution of the samples by
+// inverse-transform sampling: for each, a uniform random u in [0,1) selects
+// the sample at that point of the empirical CDF, the (⌊u·m⌋+1)'th smallest
+// of the m retained samples. Every value returned is therefore one of the
+// observed samples; nothing is interpolated between them. The random numbers
+// come from rng, or from the math/rand top-level functions if rng is nil. It
+// returns nil if there are no samples.
 //
-// The bins created will be:
-//   (-Inf,low], (low, s/nmid+low], (s/nmid+low, 2s/nmid], ..., (high,+Inf)
-//   where:
-//     s = high - low
-//     nmid = nbins-2
+// The retained samples are sorted in place, as by Percentile.
 //
-// Thus, the space (high-low) is divided into nbins-2 equally sized pieces
-// and the remaining two bins extend from -math.MaxFloat64 to low and high to
-// math.MaxFloat64.
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) Resample(n int, rng *rand.Rand) []Sample {
+	s.checkSamples("Resample")
+	if n < 0 {
+		panic("negative n")
+	}
+	if len(s.samples) == 0 {
+		return nil
+	}
+	uniform := rand.Float64
+	if rng != nil {
+		uniform = rng.Float64
+	}
+	s.sortSamples()
+	out := make([]Sample, n)
+	for i := range out {
+		out[i] = s.samples[int(uniform()*float64(len(s.samples)))]
+	}
+	return out
+}
+
+// PercentileCI returns a distribution-free confidence interval for the true
+// value at the given percentile, bounded by two of the samples. Each sample
+// independently falls below the true percentile with probability pct, so the
+// number below it is binomially distributed; low and high are the order
+// statistics chosen so that at most (1-confidence)/2 of that distribution
+// lies in each tail outside them. With too few samples for the requested
+// confidence the interval extends to the minimum or maximum sample, and has
+// lower confidence than requested. It returns (0, 0) if there are no samples.
 //
-// Low must be strictly less than high, so nbins must be at least 3.
-func (s *Stats) CreateBins(nbins int, low, high Sample) {
-	if high <= low {
-		panic("high must be greater than low")
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) PercentileCI(pct, confidence float64) (low, high Sample) {
+	s.checkSamples("PercentileCI")
+	if pct < 0 {
+		panic("pct too small")
 	}
-	if nbins < 3 {
-		panic("Not enough bins")
+	if pct > 1 {
+		panic("pct too large")
 	}
-	spread := high - low
-	s.bins = make([]Sample, nbins)
-	s.binCounts = make([]int, nbins)
-	for i := 0; i < nbins-1; i++ {
-		s.bins[i] = Sample(i)*spread/Sample(nbins-2) + low
+	if confidence <= 0 || confidence >= 1 {
+		panic("confidence out of range")
 	}
-	s.bins[nbins-1] = math.MaxFloat64
-	// save memory: stop storing samples now that we track by bins
-	s.samples = []Sample{}
+	n := len(s.samples)
+	if n == 0 {
+		return 0, 0
+	}
+	s.sortSamples()
+	if pct == 0 || pct == 1 {
+		v := s.samples[s.rankIndex(pct)]
+		return v, v
+	}
+	tail := (1 - confidence) / 2
+	lgn, _ := math.Lgamma(float64(n + 1))
+	logP, logQ := math.Log(pct), math.Log1p(-pct)
+	// cdf is P(B <= k) for the count B of samples below the percentile
+	lo, hi := 0, n-1
+	cdf := 0.0
+	for k := 0; k < n; k++ {
+		lgk, _ := math.Lgamma(float64(k + 1))
+		lgnk, _ := math.Lgamma(float64(n - k + 1))
+		cdf += math.Exp(lgn - lgk - lgnk + float64(k)*logP + float64(n-k)*logQ)
+		if cdf <= tail {
+			// B <= k is in the lower tail, so the interval may start at
+			// the (k+1)'th smallest sample
+			lo = k
+		}
+		if cdf >= 1-tail {
+			hi = k
+			break
+		}
+	}
+	if lo > n-1 {
+		lo = n - 1
+	}
+	return s.samples[lo], s.samples[hi]
 }
 
-// CreateBinsDiscard is shorthand for calling CreateBins(nbins, ...) with low
-// value s.Percentile(discardPct) and high value s.Percentile(1-discardPct)
-// with a check to make sure enough samples have been collected to make
-// discardPct meaningful (1/discardPct samples are required).
-func (s *Stats) CreateBinsDiscard(nbins int, discardPct float64) {
-	if len(s.samples) < int(1.0/discardPct) {
-		panic("Not enough samples")
+// withSamples returns a copy of s whose retained samples are replaced by
+// samples, so that sample-based methods can be applied to them.
+func (s Stats) withSamples(samples []Sample) Stats {
+	s.samples = samples
+	s.sorted = false
+	s.trackIndices = false
+	s.indices = nil
+	return s
+}
+
+// PercentileDetail returns the same value as Percentile along with the
+// fractional rank pct*(Count()-1) it was derived from and the index of the
+// selected sample in the sorted samples, which is that rank rounded to an
+// integer as set by SetRankRounding.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) PercentileDetail(pct float64) (value Sample, rankFrac float64, index int) {
+	s.checkSamples("PercentileDetail")
+	if len(s.samples) == 0 {
+		return 0, 0, 0
 	}
-	s.CreateBins(nbins, s.Percentile(discardPct), s.Percentile(1.0-discardPct))
+	index = s.rankIndex(pct)
+	rankFrac = float64(len(s.samples)-1) * pct
+	s.sortSamples()
+	return s.samples[index], rankFrac, index
 }
 
-// Returns the count and low and high ends of the i'th bin.
+// PercentileBracket returns the two samples bracketing the rank
+// pct*(Count()-1) in the sorted samples, i.e. the samples at the floor and
+// ceiling of that rank. They are equal when the rank is a whole number.
 //
-// The bin interval is (low,high]
-func (s Stats) Bin(i int) (count int, low, high Sample) {
-	count = s.binCounts[i]
-	high = s.bins[i]
-	if i == 0 {
-		low = -math.MaxFloat64
-	} else {
-		low = s.bins[i-1]
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) PercentileBracket(pct float64) (lower, upper Sample) {
+	s.checkSamples("PercentileBracket")
+	if len(s.samples) == 0 {
+		return 0, 0
 	}
-	return
+	if pct < 0 {
+		panic("pct too small")
+	}
+	if pct > 1 {
+		panic("pct too large")
+	}
+	s.sortSamples()
+	rank := float64(len(s.samples)-1) * pct
+	return s.samples[int(math.Floor(rank))], s.samples[int(math.Ceil(rank))]
 }
 
-// Returns the number of bins
-func (s Stats) NBins() int {
-	return len(s.bins)
+// PercentileExcel returns the value at the given percentile, linearly
+// interpolating between the two nearest samples the way Excel's
+// PERCENTILE.INC does: the rank pct*(Count()-1) is computed and the samples
+// at the floor and ceiling of that rank are blended by its fractional part.
+//
+// Percentile instead returns the sample nearest to that rank, so it always
+// returns an actual sample value.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) PercentileExcel(pct float64) Sample {
+	s.checkSamples("PercentileExcel")
+	if len(s.samples) == 0 {
+		return 0
+	}
+	if pct < 0 {
+		panic("pct too small")
+	}
+	if pct > 1 {
+		panic("pct too large")
+	}
+	s.sortSamples()
+	rank := float64(len(s.samples)-1) * pct
+	i := int(rank)
+	if i == len(s.samples)-1 {
+		return s.samples[i]
+	}
+	frac := Sample(rank - float64(i))
+	return s.samples[i] + frac*(s.samples[i+1]-s.samples[i])
+}
+
+// PercentileType returns the value at the given percentile using one of the
+// nine sample quantile definitions of Hyndman and Fan, numbered as for the
+// type argument of R's quantile function.
+//
+// With the samples sorted as x[1] <= ... <= x[n], each type computes a
+// position h, and the result is x[j] + g*(x[j+1]-x[j]) where j = floor(h) and
+// g = h-j, with positions outside [1,n] clamped to the ends. The supported
+// types are:
+//
+//	1: h = n*pct, with g rounded up to 1 if it is non-zero, i.e. the inverse
+//	   of the empirical distribution function
+//	4: h = n*pct, linear interpolation of the empirical distribution function
+//	6: h = (n+1)*pct, as used by Minitab and SPSS
+//	7: h = (n-1)*pct + 1, R's default and the same as PercentileExcel
+//	8: h = (n+1/3)*pct + 1/3, approximately median-unbiased
+//
+// Any other type panics.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) PercentileType(pct float64, hfType int) Sample {
+	s.checkSamples("PercentileType")
+	var h float64
+	n := float64(len(s.samples))
+	switch hfType {
+	case 1, 4:
+		h = n * pct
+	case 6:
+		h = (n + 1) * pct
+	case 7:
+		h = (n-1)*pct + 1
+	case 8:
+		h = (n+1.0/3)*pct + 1.0/3
+	default:
+		panic("unsupported Hyndman-Fan type")
+	}
+	if len(s.samples) == 0 {
+		return 0
+	}
+	if pct < 0 {
+		panic("pct too small")
+	}
+	if pct > 1 {
+		panic("pct too large")
+	}
+	s.sortSamples()
+	// like R, ignore rounding errors in h
+	const fuzz = 4 * 2.220446049250313e-16
+	j := math.Floor(h + fuzz)
+	g := h - j
+	if math.Abs(g) < fuzz {
+		g = 0
+	}
+	if hfType == 1 && g > 0 {
+		g = 1
+	}
+	x := func(i int) Sample {
+		if i < 1 {
+			i = 1
+		}
+		if i > len(s.samples) {
+			i = len(s.samples)
+		}
+		return s.samples[i-1]
+	}
+	lo, hi := x(int(j)), x(int(j)+1)
+	if g == 0 || lo == hi {
+		return lo
+	}
+	return lo + Sample(g)*(hi-lo)
+}
+
+// PercentileTable returns the samples at the percentiles 0, step, 2*step, ...,
+// 1, sorting the samples only once. For example, a step of 0.1 gives the 11
+// deciles and 0.01 gives all 101 percentiles. It panics unless step is in
+// (0, 1] and divides 1 into a whole number of steps.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) PercentileTable(step float64) []Sample {
+	s.checkSamples("PercentileTable")
+	if step <= 0 || step > 1 {
+		panic("step must be in (0, 1]")
+	}
+	n := int(math.Floor(1/step + 0.5))
+	if math.Abs(float64(n)*step-1) > 1e-9 {
+		panic("step does not divide 1 evenly")
+	}
+	table := make([]Sample, n+1)
+	if len(s.samples) == 0 {
+		return table
+	}
+	s.sortSamples()
+	for i := range table {
+		table[i] = s.samples[s.rankIndex(math.Min(float64(i)*step, 1))]
+	}
+	return table
+}
+
+// Downsample returns n samples, in ascending order, chosen to represent the
+// distribution of all the samples: the i'th is the sample at the percentile
+// (i+0.5)/n, so each stands for an equal share of the samples. This allows a
+// receiver to reconstruct an approximation of the distribution from far
+// fewer values.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) Downsample(n int) []Sample {
+	s.checkSamples("Downsample")
+	if n < 1 {
+		panic("n must be positive")
+	}
+	if len(s.samples) == 0 {
+		return []Sample{}
+	}
+	s.sortSamples()
+	d := make([]Sample, n)
+	for i := range d {
+		d[i] = s.samples[s.rankIndex((float64(i)+0.5)/float64(n))]
+	}
+	return d
+}
+
+// rankIndex returns the index of the sample at the given percentile in the
+// sorted samples.
+func (s Stats) rankIndex(pct float64) int {
+	if pct < 0 {
+		panic("pct too small")
+	}
+	if pct > 1 {
+		panic("pct too large")
+	}
+	// scale pct into int in [0, len-1]
+	rank := float64(len(s.samples)-1) * pct
+	switch s.rounding {
+	case Floor:
+		return int(rank)
+	case Ceil:
+		return int(math.Ceil(rank))
+	case RoundHalfEven:
+		return int(math.RoundToEven(rank))
+	}
+	// Adding 0.5 turns the implicit floor operation of int() into a rounding operation
+	return int(rank + 0.5)
+}
+
+// OrderStatistic returns the k'th smallest sample, counting from 0. It panics
+// if k is not in [0, Count()).
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) OrderStatistic(k int) Sample {
+	s.checkSamples("OrderStatistic")
+	if k < 0 || k >= len(s.samples) {
+		panic("k out of range")
+	}
+	s.sortSamples()
+	return s.samples[k]
+}
+
+// SumTopK returns the sum of the k largest samples. It panics if k is not in
+// [0, Count()].
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) SumTopK(k int) Sample {
+	s.checkSamples("SumTopK")
+	if k < 0 || k > len(s.samples) {
+		panic("k out of range")
+	}
+	s.sortSamples()
+	var sum Sample
+	for _, val := range s.samples[len(s.samples)-k:] {
+		sum += val
+	}
+	return sum
+}
+
+// SumBottomK returns the sum of the k smallest samples. It panics if k is not
+// in [0, Count()].
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) SumBottomK(k int) Sample {
+	s.checkSamples("SumBottomK")
+	if k < 0 || k > len(s.samples) {
+		panic("k out of range")
+	}
+	s.sortSamples()
+	var sum Sample
+	for _, val := range s.samples[:k] {
+		sum += val
+	}
+	return sum
+}
+
+// CountAbove returns the number of samples greater than threshold.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) CountAbove(threshold Sample) int {
+	s.checkSamples("CountAbove")
+	n := 0
+	for _, val := range s.samples {
+		if val > threshold {
+			n++
+		}
+	}
+	return n
+}
+
+// ExpectedExceedances returns the expected number of samples greater than
+// threshold among the next futureN samples, futureN times the fraction of the
+// samples so far which exceed it. This naively assumes the distribution is
+// stationary, so that future samples exceed threshold as often as past ones;
+// trends, seasonality and bursts are not accounted for. It is NaN if there
+// are no samples.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) ExpectedExceedances(threshold Sample, futureN int) float64 {
+	s.checkSamples("ExpectedExceedances")
+	return float64(futureN) * float64(s.CountAbove(threshold)) / float64(len(s.samples))
+}
+
+// Rank returns the number of samples strictly less than val, which is the
+// index, counting from 0, at which val would be inserted into the sorted
+// samples ahead of any equal to it.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) Rank(val Sample) int {
+	s.checkSamples("Rank")
+	s.sortSamples()
+	return sort.Search(len(s.samples), func(i int) bool {
+		return s.samples[i] >= val
+	})
+}
+
+// FrequencyOf returns the fraction of the samples exactly equal to val, for
+// discrete data. It is NaN if there are no samples.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) FrequencyOf(val Sample) float64 {
+	s.checkSamples("FrequencyOf")
+	s.sortSamples()
+	start := s.Rank(val)
+	end := start + sort.Search(len(s.samples)-start, func(i int) bool {
+		return s.samples[start+i] > val
+	})
+	return float64(end-start) / float64(len(s.samples))
+}
+
+// CommonPercentiles returns the 50th, 90th, 95th, 99th and 99.9th percentiles
+// keyed by "p50", "p90", "p95", "p99" and "p999" respectively. The samples are
+// only sorted once.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) CommonPercentiles() map[string]Sample {
+	s.checkSamples("CommonPercentiles")
+	pcts := map[string]float64{
+		"p50":  .5,
+		"p90":  .9,
+		"p95":  .95,
+		"p99":  .99,
+		"p999": .999,
+	}
+	m := make(map[string]Sample, len(pcts))
+	if len(s.samples) == 0 {
+		for k := range pcts {
+			m[k] = 0
+		}
+		return m
+	}
+	s.sortSamples()
+	for k, pct := range pcts {
+		m[k] = s.samples[s.rankIndex(pct)]
+	}
+	return m
+}
+
+// Median returns the median of the samples.
+//
+// It may not be called after CreateBins, which discards the samples from
+// which the percentile is calculated.
+func (s Stats) Median() float64 {
+	s.checkSamples("Median")
+	l := len(s.samples)
+	if l == 0 {
+		return 0
+	}
+	s.sortSamples()
+	half, rem := l/2, l%2
+	if rem == 0 {
+		return (float64(s.samples[half]) + float64(s.samples[half-1])) / 2
+	}
+	return float64(s.samples[half])
+}
+
+// MedianStable is like Median, but finds the median in a copy of the samples,
+// leaving the retained samples in the order they were added. Median and
+// the percentile methods otherwise reorder them in place. The copy takes
+// memory proportional to the number of samples on every call.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) MedianStable() float64 {
+	s.checkSamples("MedianStable")
+	if s.sorted {
+		return s.Median()
+	}
+	return s.withSamples(append([]Sample(nil), s.samples...)).Median()
+}
+
+// NearestToMean returns the sample closest to the mean, as a representative
+// example of the data. If two samples are equally close, the smaller is
+// returned. It returns 0 if there are no samples.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) NearestToMean() Sample {
+	s.checkSamples("NearestToMean")
+	if len(s.samples) == 0 {
+		return 0
+	}
+	mean := s.Mean()
+	best := s.samples[0]
+	bestDist := math.Abs(float64(best) - mean)
+	for _, val := range s.samples[1:] {
+		d := math.Abs(float64(val) - mean)
+		if d < bestDist || d == bestDist && val < best {
+			best, bestDist = val, d
+		}
+	}
+	return best
+}
+
+// RobustRange returns the values at the pct and 1-pct percentiles, giving a
+// range that ignores the most extreme samples at either end.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) RobustRange(pct float64) (low, high Sample) {
+	s.checkSamples("RobustRange")
+	return s.Percentile(pct), s.Percentile(1 - pct)
+}
+
+// TrimmedRange returns the smallest and largest samples remaining after the
+// dropEach smallest and dropEach largest have been discarded. Unlike
+// RobustRange, a fixed number of samples is dropped however many there are.
+// It panics if that would leave no samples.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) TrimmedRange(dropEach int) (low, high Sample) {
+	s.checkSamples("TrimmedRange")
+	if dropEach < 0 {
+		panic("dropEach must not be negative")
+	}
+	if 2*dropEach >= len(s.samples) {
+		panic("Not enough samples")
+	}
+	s.sortSamples()
+	return s.samples[dropEach], s.samples[len(s.samples)-1-dropEach]
+}
+
+// DistinctCount returns the number of distinct sample values. The count is
+// exact, which requires the samples to be retained and sorted; see
+// TrackDistinct for an approximate alternative which does not.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) DistinctCount() int {
+	s.checkSamples("DistinctCount")
+	if len(s.samples) == 0 {
+		return 0
+	}
+	s.sortSamples()
+	n := 1
+	for i := 1; i < len(s.samples); i++ {
+		if s.samples[i] != s.samples[i-1] {
+			n++
+		}
+	}
+	return n
+}
+
+// QuartileCoeffDispersion returns the quartile coefficient of dispersion,
+// (Q3-Q1)/(Q3+Q1), where Q1 and Q3 are the 25th and 75th percentiles. It
+// returns 0 if Q3+Q1 is 0.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) QuartileCoeffDispersion() float64 {
+	s.checkSamples("QuartileCoeffDispersion")
+	q1, q3 := float64(s.Percentile(.25)), float64(s.Percentile(.75))
+	if q3+q1 == 0 {
+		return 0
+	}
+	return (q3 - q1) / (q3 + q1)
+}
+
+// CentralSpread returns the width of the range covering the central fraction
+// of the samples, Percentile((1+fraction)/2) - Percentile((1-fraction)/2).
+// CentralSpread(0.5) is the interquartile range.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) CentralSpread(fraction float64) Sample {
+	s.checkSamples("CentralSpread")
+	return s.Percentile((1+fraction)/2) - s.Percentile((1-fraction)/2)
+}
+
+// InterPercentileRange returns Percentile(highPct) - Percentile(lowPct). For
+// example, InterPercentileRange(0.25, 0.75) is the interquartile range. It
+// panics unless 0 <= lowPct < highPct <= 1.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) InterPercentileRange(lowPct, highPct float64) Sample {
+	s.checkSamples("InterPercentileRange")
+	if lowPct < 0 {
+		panic("lowPct too small")
+	}
+	if highPct > 1 {
+		panic("highPct too large")
+	}
+	if lowPct >= highPct {
+		panic("lowPct must be less than highPct")
+	}
+	return s.Percentile(highPct) - s.Percentile(lowPct)
+}
+
+// DistinctCountApprox returns an estimate of the number of distinct sample
+// values; see TrackDistinct for its accuracy. It needs no retained samples,
+// so it may be called after CreateBins.
+//
+// It panics unless the Stats was created with the TrackDistinct option.
+func (s Stats) DistinctCountApprox() int {
+	if s.distinct == nil {
+		panic("DistinctCountApprox() requires the TrackDistinct() option")
+	}
+	return s.distinct.estimate()
+}
+
+// Winsorized returns a copy of the samples in which the lowest and highest
+// frac of the values have been clamped to the nearest remaining value. For
+// example, with frac 0.1 the lowest 10% of samples are replaced by the 10th
+// percentile value and the highest 10% by the 90th percentile value. The
+// samples keep their positions, as given by TrackIndices if it is set, and s
+// itself is not modified.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) Winsorized(frac float64) []Sample {
+	s.checkSamples("Winsorized")
+	if frac < 0 {
+		panic("frac too small")
+	}
+	if frac >= 0.5 {
+		panic("frac too large")
+	}
+	w := append([]Sample(nil), s.insertionOrder()...)
+	l := len(w)
+	k := int(float64(l) * frac)
+	if k == 0 {
+		return w
+	}
+	sorted := append([]Sample(nil), w...)
+	sort.Sort(sampleSlice(sorted))
+	low, high := sorted[k], sorted[l-1-k]
+	for i, val := range w {
+		if val < low {
+			w[i] = low
+		} else if val > high {
+			w[i] = high
+		}
+	}
+	return w
+}
+
+// Mean returns the mean of the samples.
+func (s Stats) Mean() float64 {
+	return float64(s.sum) / float64(s.count)
+}
+
+// WeightedExpectation returns the mean of g(x) over the samples x. With g the
+// identity this is the mean; with g(x) = x*x it is the mean of the squares.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) WeightedExpectation(g func(Sample) float64) float64 {
+	s.checkSamples("WeightedExpectation")
+	var sum float64
+	for _, val := range s.samples {
+		sum += g(val)
+	}
+	return sum / float64(len(s.samples))
+}
+
+// MeanAbsoluteDeviation returns the mean of the absolute differences between
+// the samples and their mean. This differs from the median absolute
+// deviation (MAD), which takes the median of the absolute differences from
+// the median and so is less affected by outliers.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) MeanAbsoluteDeviation() float64 {
+	s.checkSamples("MeanAbsoluteDeviation")
+	m := s.Mean()
+	var sum float64
+	for _, val := range s.samples {
+		sum += math.Abs(float64(val) - m)
+	}
+	return sum / float64(len(s.samples))
+}
+
+// MAD returns the median absolute deviation of the samples: the median of
+// the absolute differences between the samples and their median.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) MAD() float64 {
+	s.checkSamples("MAD")
+	med := s.Median()
+	devs := make([]Sample, len(s.samples))
+	for i, val := range s.samples {
+		devs[i] = Sample(math.Abs(float64(val) - med))
+	}
+	return s.withSamples(devs).Median()
+}
+
+// ZScores returns the z-score (x-mean)/stddev of each retained sample x, using
+// Stddev and so following WithUnbiasedVariance. If the standard deviation is
+// 0 every z-score is 0. The z-scores are in the order the samples were added.
+//
+// It panics unless the Stats was created with the TrackIndices option, and
+// may not be called after CreateBins, which discards the samples.
+func (s Stats) ZScores() []float64 {
+	s.checkSamples("ZScores")
+	if !s.trackIndices {
+		panic("ZScores() requires the TrackIndices() option")
+	}
+	z := make([]float64, len(s.samples))
+	m, sd := s.Mean(), s.Stddev()
+	if sd == 0 {
+		return z
+	}
+	for i, val := range s.insertionOrder() {
+		z[i] = (float64(val) - m) / sd
+	}
+	return z
+}
+
+// RobustZScore returns the modified z-score of val, 0.6745*(val-median)/MAD.
+// Unlike the standard z-score, which uses the mean and standard deviation,
+// it is barely affected by outliers in the samples. The constant scales the
+// MAD to be comparable to the standard deviation for normal data.
+//
+// If the MAD is 0, the result is 0 if val equals the median and ±Inf
+// otherwise.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) RobustZScore(val Sample) float64 {
+	s.checkSamples("RobustZScore")
+	d := float64(val) - s.Median()
+	if d == 0 {
+		return 0
+	}
+	return 0.6745 * d / s.MAD()
+}
+
+// GeometricMean returns the geometric mean of the samples. It is only
+// meaningful if all samples are positive.
+//
+// It panics unless the Stats was created with the TrackLogReciprocal option.
+func (s Stats) GeometricMean() float64 {
+	if !s.trackLogRecip {
+		panic("GeometricMean() requires the TrackLogReciprocal() option")
+	}
+	return math.Exp(s.sumLog / float64(s.count))
+}
+
+// HarmonicMean returns the harmonic mean of the samples. It is only
+// meaningful if all samples are positive.
+//
+// It panics unless the Stats was created with the TrackLogReciprocal option.
+func (s Stats) HarmonicMean() float64 {
+	if !s.trackLogRecip {
+		panic("HarmonicMean() requires the TrackLogReciprocal() option")
+	}
+	return float64(s.count) / s.sumRecip
+}
+
+// Variance returns the variance of the samples. This is the population
+// variance, with denominator Count(), unless the Stats was created with the
+// WithUnbiasedVariance option.
+func (s Stats) Variance() float64 {
+	m := s.Mean()
+	v := float64(s.sum2)/float64(s.count) - m*m
+	if s.unbiased {
+		v *= float64(s.count) / float64(s.count-1)
+	}
+	return v
+}
+
+// Stddev returns the standard deviation of the samples, the square root of
+// Variance.
+func (s Stats) Stddev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// MSE returns the mean squared error of the samples against target, the mean
+// of (x-target)². It is computed from the running sums as
+// Σx²/n - 2·target·mean + target², so it may be called after CreateBins. It
+// is NaN if there are no samples.
+func (s Stats) MSE(target Sample) float64 {
+	t := float64(target)
+	mse := float64(s.sum2)/float64(s.count) - 2*t*s.Mean() + t*t
+	// rounding can leave a tiny negative residue when every sample is target
+	return math.Max(mse, 0)
+}
+
+// RMSE returns the root mean squared error of the samples against target, the
+// square root of MSE.
+func (s Stats) RMSE(target Sample) float64 {
+	return math.Sqrt(s.MSE(target))
+}
+
+// PercentRSD returns the relative standard deviation as a percentage,
+// 100*Stddev()/Mean(). The variance convention follows WithUnbiasedVariance;
+// lab %RSD figures normally use the unbiased form. If the mean is 0 the
+// result is ±Inf, or NaN if the standard deviation is also 0.
+func (s Stats) PercentRSD() float64 {
+	return 100 * s.Stddev() / s.Mean()
+}
+
+// MeanExceeds returns the z statistic for a one-sided test that the true mean
+// exceeds threshold, (Mean()-threshold)/SE, where the standard error SE is
+// the sample standard deviation (with denominator Count()-1, whatever the
+// variance option) divided by the square root of Count(). Large positive
+// values are evidence that the mean is above threshold. The caller maps z to
+// a p-value; for large counts 1-Φ(z) from the standard normal distribution,
+// and from Student's t distribution with Count()-1 degrees of freedom for
+// small ones. Only the running sums are needed, so it may be called after
+// CreateBins.
+//
+// It returns NaN for fewer than 2 samples. If the samples are all equal the
+// result is ±Inf, or NaN if they also equal threshold.
+func (s Stats) MeanExceeds(threshold Sample) float64 {
+	if s.count < 2 {
+		return math.NaN()
+	}
+	n := float64(s.count)
+	m := s.Mean()
+	v := (float64(s.sum2)/n - m*m) * n / (n - 1)
+	if v < 0 {
+		// rounding in the running sums
+		v = 0
+	}
+	return (m - float64(threshold)) / math.Sqrt(v/n)
+}
+
+// SigmaBand classifies val by its distance from the mean in standard
+// deviations: 0 if within 1σ, 1 if within 2σ, 2 if within 3σ and 3 beyond
+// that. Only the mean and standard deviation are needed, so it may be called
+// after CreateBins.
+//
+// If the standard deviation is 0, val is in band 0 if it equals the mean and
+// in band 3 otherwise.
+func (s Stats) SigmaBand(val Sample) int {
+	m, sd := s.Mean(), s.Stddev()
+	d := math.Abs(float64(val) - m)
+	if sd == 0 {
+		if d == 0 {
+			return 0
+		}
+		return 3
+	}
+	z := d / sd
+	switch {
+	case z <= 1:
+		return 0
+	case z <= 2:
+		return 1
+	case z <= 3:
+		return 2
+	}
+	return 3
+}
+
+// CentralMoment returns the n'th central moment of the samples, the mean of
+// (x-mean)^n. The second central moment is the population variance.
+//
+// Moments up to the second are derived from running sums, but no such sums
+// are kept for arbitrary n, so higher moments are computed from the samples
+// and may not be requested after CreateBins.
+func (s Stats) CentralMoment(n int) float64 {
+	switch {
+	case n < 0:
+		panic("n must not be negative")
+	case n == 0:
+		return 1
+	case n == 1:
+		return 0
+	case n == 2:
+		m := s.Mean()
+		return float64(s.sum2)/float64(s.count) - m*m
+	}
+	s.checkSamples("CentralMoment")
+	m := s.Mean()
+	var sum float64
+	for _, val := range s.samples {
+		sum += math.Pow(float64(val)-m, float64(n))
+	}
+	return sum / float64(len(s.samples))
+}
+
+// FractionWithinSigma returns the fraction of the samples within k standard
+// deviations of the mean. For normally distributed data this is about 0.68,
+// 0.95 and 0.997 for k of 1, 2 and 3.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) FractionWithinSigma(k float64) float64 {
+	s.checkSamples("FractionWithinSigma")
+	m, limit := s.Mean(), k*s.Stddev()
+	n := 0
+	for _, val := range s.samples {
+		if math.Abs(float64(val)-m) <= limit {
+			n++
+		}
+	}
+	return float64(n) / float64(len(s.samples))
+}
+
+// Spread returns the difference of the maximal and minimal sample values.
+func (s Stats) Spread() Sample {
+	if s.min > s.max {
+		return 0
+	}
+	return s.max - s.min
+}
+
+// QQNormal returns the points of a normal QQ plot of the samples: sample
+// holds the samples in ascending order, and theoretical[i] the standard normal
+// quantile at the plotting position (i+0.5)/n. The points lie close to a
+// straight line, of slope Stddev() and intercept Mean(), if the samples are
+// normally distributed.
+//
+// It may not be called after CreateBins, which discards the samples.
+func (s Stats) QQNormal() (theoretical, sample []float64) {
+	s.checkSamples("QQNormal")
+	s.sortSamples()
+	n := len(s.samples)
+	theoretical = make([]float64, n)
+	sample = make([]float64, n)
+	for i, val := range s.samples {
+		p := (float64(i) + 0.5) / float64(n)
+		theoretical[i] = math.Sqrt2 * math.Erfinv(2*p-1)
+		sample[i] = float64(val)
+	}
+	return theoretical, sample
+}
+
+// insertionOrder returns the retained samples in the order they were added
+// if the TrackIndices option is set, and as they are currently held otherwise.
+func (s Stats) insertionOrder() []Sample {
+	if !s.trackIndices {
+		return s.samples
+	}
+	ordered := indexedSamples{
+		samples: append([]Sample(nil), s.samples...),
+		indices: append([]int(nil), s.indices...),
+	}
+	sort.Sort(byIndex{ordered})
+	return ordered.samples
+}
+
+// byIndex sorts indexedSamples by their insertion indices.
+type byIndex struct {
+	indexedSamples
+}
+
+func (s byIndex) Less(i, j int) bool {
+	return s.indices[i] < s.indices[j]
+}
+
+// MannKendall performs the Mann-Kendall test for a monotonic trend in the
+// samples taken in the order they were added. S is the number of later
+// samples greater than earlier ones less the number smaller, and tau is S
+// divided by the number of pairs, n(n-1)/2, so it lies in [-1,1]. trend is 1
+// for an increasing and -1 for a decreasing trend significant at the 5% level
+// (two-sided, using the normal approximation to the distribution of S without
+// a correction for ties), and 0 otherwise. It returns (0, 0) for fewer than 2
+// samples. It takes time proportional to the square of the number of samples.
+//
+// It panics unless the Stats was created with the TrackIndices option, and
+// may not be called after CreateBins, which discards the samples.
+func (s Stats) MannKendall() (tau float64, trend int) {
+	s.checkSamples("MannKendall")
+	if !s.trackIndices {
+		panic("MannKendall() requires the TrackIndices() option")
+	}
+	x := s.insertionOrder()
+	n := len(x)
+	if n < 2 {
+		return 0, 0
+	}
+	S := 0
+	for i := 0; i < n-1; i++ {
+		for j := i + 1; j < n; j++ {
+			switch {
+			case x[j] > x[i]:
+				S++
+			case x[j] < x[i]:
+				S--
+			}
+		}
+	}
+	nf := float64(n)
+	tau = float64(S) / (nf * (nf - 1) / 2)
+	sd := math.Sqrt(nf * (nf - 1) * (2*nf + 5) / 18)
+	// continuity corrected
+	switch {
+	case S > 0 && float64(S-1)/sd > 1.96:
+		trend = 1
+	case S < 0 && float64(S+1)/sd < -1.96:
+		trend = -1
+	}
+	return tau, trend
+}
+
+// Autocorrelation returns the lag-k autocorrelation of the samples taken in
+// the order they were added,
+//
+//	Σ(x[t]-mean)(x[t+lag]-mean) / Σ(x[t]-mean)²
+//
+// where the numerator sums over the n-lag overlapping pairs and the
+// denominator over all n samples. Using the full-series variance as the
+// normalization, as is conventional, biases the result towards 0 by a factor
+// of about (n-lag)/n but guarantees the autocorrelations form a valid
+// correlation sequence. Lag 0 gives 1, and the result is NaN if the samples
+// are all equal.
+//
+// It panics unless the Stats was created with the TrackIndices option, and
+// may not be called after CreateBins, which discards the samples.
+func (s Stats) Autocorrelation(lag int) float64 {
+	s.checkSamples("Autocorrelation")
+	if !s.trackIndices {
+		panic("Autocorrelation() requires the TrackIndices() option")
+	}
+	x := s.insertionOrder()
+	if lag < 0 || lag >= len(x) {
+		panic("lag out of range")
+	}
+	mean := s.Mean()
+	var num, den float64
+	for t, val := range x {
+		d := float64(val) - mean
+		den += d * d
+		if t+lag < len(x) {
+			num += d * (float64(x[t+lag]) - mean)
+		}
+	}
+	return num / den
+}
+
+// Normalize returns val scaled so that the minimum sample maps to 0 and the
+// maximum to 1, (val-Min())/(Max()-Min()), clamped to [0,1]. If all the
+// samples are equal, or there are none, it returns 0.5.
+func (s Stats) Normalize(val Sample) float64 {
+	spread := s.Spread()
+	if spread == 0 {
+		return 0.5
+	}
+	return math.Max(0, math.Min(1, float64((val-s.min)/spread)))
+}
+
+// NormalizeAll returns Normalize of each retained sample, in the order the
+// samples were added.
+//
+// It panics unless the Stats was created with the TrackIndices option, and
+// may not be called after CreateBins, which discards the samples.
+func (s Stats) NormalizeAll() []float64 {
+	s.checkSamples("NormalizeAll")
+	if !s.trackIndices {
+		panic("NormalizeAll() requires the TrackIndices() option")
+	}
+	norm := make([]float64, len(s.samples))
+	for i, val := range s.insertionOrder() {
+		norm[i] = s.Normalize(val)
+	}
+	return norm
+}
+
+// A BinInterval determines which end of each bin's interval is closed, and so
+// which bin a sample exactly on a boundary is counted in.
+type BinInterval int
+
+const (
+	// UpperInclusive bins are closed at the top, (low,high]. This is the
+	// convention used by CreateBins and by Prometheus's "le" buckets.
+	UpperInclusive BinInterval = iota
+	// LowerInclusive bins are closed at the bottom, [low,high), so that
+	// the bins are (-Inf,low), [low, s/nmid+low), ..., [high,+Inf).
+	LowerInclusive
+)
+
+// CreateBins divides the sample space into nbins bins for tracking counts.
+//
+// As samples are added, the count for the corresponding bin will be
+// incremented and the sample value will not be stored.
+//
+// This saves memory at the expense of granularity. Percentile() and Median()
+// cannot be called after CreateBins() because they are no longer meaningful.
+// Instead, use Bin(i) to inspect the distribution of data by bin. Any existing
+// stored samples are discarded.
+//
+// The bins created will be:
+//   (-Inf,low], (low, s/nmid+low], (s/nmid+low, 2s/nmid], ..., (high,+Inf)
+//   where:
+//     s = high - low
+//     nmid = nbins-2
+//
+// Thus, the space (high-low) is divided into nbins-2 equally sized pieces
+// and the remaining two bins extend from -math.MaxFloat64 to low and high to
+// math.MaxFloat64.
+//
+// Low must be strictly less than high, so nbins must be at least 3.
+func (s *Stats) CreateBins(nbins int, low, high Sample) {
+	s.CreateBinsInterval(nbins, low, high, UpperInclusive)
+}
+
+// RecommendBinCount returns the number of bins to pass to CreateBins(nbins,
+// low, high) for BinnedPercentile estimates within targetError, in the units
+// of the samples, of the true percentiles inside [low,high].
+//
+// The model is deliberately simple: an estimate interpolates within the bin
+// holding the percentile, so it can be out by at most that bin's width, and
+// the interior bins are made no wider than targetError. This is pessimistic
+// for distributions which are smooth on the scale of a bin, where the error
+// is typically a small fraction of the width. Percentiles falling outside
+// [low,high] are not covered by the bound.
+func RecommendBinCount(low, high Sample, targetError float64) int {
+	if high <= low {
+		panic("high must be greater than low")
+	}
+	if targetError <= 0 {
+		panic("targetError must be positive")
+	}
+	// interior bins, plus the two edge bins
+	return int(math.Ceil(float64(high-low)/targetError)) + 2
+}
+
+// CreateBinsInterval is like CreateBins, but with the given convention for
+// which end of each bin is closed.
+func (s *Stats) CreateBinsInterval(nbins int, low, high Sample, interval BinInterval) {
+	if high <= low {
+		panic("high must be greater than low")
+	}
+	if nbins < 3 {
+		panic("Not enough bins")
+	}
+	spread := high - low
+	s.bins = make([]Sample, nbins)
+	s.binCounts = make([]int, nbins)
+	for i := 0; i < nbins-1; i++ {
+		s.bins[i] = Sample(i)*spread/Sample(nbins-2) + low
+	}
+	s.bins[nbins-1] = math.MaxFloat64
+	s.binSums = nil
+	s.interval = interval
+	s.keepSamples = false
+	// save memory: stop storing samples now that we track by bins
+	s.samples = []Sample{}
+	s.sorted = true
+	s.indices = nil
+	if s.deltas != nil {
+		// differences between samples inside the range lie within ±spread
+		s.deltas.CreateBinsInterval(nbins, -spread, spread, interval)
+	}
+}
+
+// CreateBinsTrackSum is like CreateBins, but additionally tracks the sum of
+// the samples falling in each bin so that BinMean can report where within a
+// bin its samples actually lie, rather than assuming they are spread evenly.
+func (s *Stats) CreateBinsTrackSum(nbins int, low, high Sample) {
+	s.CreateBins(nbins, low, high)
+	s.binSums = make([]Sample, nbins)
+}
+
+// RebinReset replaces the bins of an already binned Stats with new ones as
+// for CreateBins, keeping the count, sum, mean, standard deviation, minimum
+// and maximum of all samples added so far. The historical bin counts are
+// lost: the new bins start out empty, as there are no retained samples from
+// which to recompute them. The exception is after CreateBinsKeepSamples, when
+// the retained samples are kept and counted in the new bins. Per-bin sums
+// remain tracked if they were before.
+//
+// It may only be called after CreateBins.
+func (s *Stats) RebinReset(nbins int, low, high Sample) {
+	s.checkBins("RebinReset")
+	trackSum := s.binSums != nil
+	keep, samples, indices, deltas := s.keepSamples, s.samples, s.indices, s.deltaSamples()
+	s.CreateBinsInterval(nbins, low, high, s.interval)
+	if trackSum {
+		s.binSums = make([]Sample, nbins)
+	}
+	if keep {
+		s.keepBinnedSamples(samples, indices, deltas)
+	}
+}
+
+// CreateBinsKeepSamples is like CreateBins, but the samples are retained, both
+// those already added (which are counted in the new bins) and those added
+// later. Methods such as Percentile and Median which need the samples may
+// therefore still be called, alongside the bin-based methods.
+//
+// This forgoes the memory saving of binning: the samples take memory
+// proportional to their count, in addition to the bins.
+func (s *Stats) CreateBinsKeepSamples(nbins int, low, high Sample) {
+	samples, indices, deltas := s.samples, s.indices, s.deltaSamples()
+	s.CreateBins(nbins, low, high)
+	s.keepBinnedSamples(samples, indices, deltas)
+}
+
+// deltaSamples returns the retained samples of the deltas, if tracked.
+func (s Stats) deltaSamples() []Sample {
+	if s.deltas == nil {
+		return nil
+	}
+	return s.deltas.samples
+}
+
+// keepBinnedSamples restores samples and their insertion indices as the
+// retained samples of a binned Stats, counting them in the bins, and likewise
+// the retained deltas.
+func (s *Stats) keepBinnedSamples(samples []Sample, indices []int, deltas []Sample) {
+	if s.deltas != nil {
+		s.deltas.keepBinnedSamples(deltas, nil, nil)
+	}
+	s.samples = samples
+	s.sorted = sort.IsSorted(sampleSlice(samples))
+	s.indices = indices
+	s.keepSamples = true
+	for _, val := range samples {
+		s.binSample(val)
+	}
+}
+
+// CreateBinsDiscard is shorthand for calling CreateBins(nbins, ...) with low
+// value s.Percentile(discardPct) and high value s.Percentile(1-discardPct)
+// with a check to make sure enough samples have been collected to make
+// discardPct meaningful (1/discardPct samples are required).
+func (s *Stats) CreateBinsDiscard(nbins int, discardPct float64) {
+	if len(s.samples) < int(1.0/discardPct) {
+		panic("Not enough samples")
+	}
+	s.CreateBins(nbins, s.Percentile(discardPct), s.Percentile(1.0-discardPct))
+}
+
+// CreateBinsIQR calls CreateBins(nbins, ...) with the Tukey fences
+// Q1 - k*IQR and Q3 + k*IQR as the low and high values, where Q1 and Q3 are
+// s.Percentile(0.25) and s.Percentile(0.75) and IQR = Q3 - Q1. Unlike
+// CreateBinsDiscard this excludes outliers from the range without discarding
+// a fixed fraction of the samples. At least 4 samples are required.
+func (s *Stats) CreateBinsIQR(nbins int, k float64) {
+	if len(s.samples) < 4 {
+		panic("Not enough samples")
+	}
+	q1, q3 := s.Percentile(0.25), s.Percentile(0.75)
+	iqr := q3 - q1
+	s.CreateBins(nbins, q1-Sample(k)*iqr, q3+Sample(k)*iqr)
+}
+
+// binIndex returns the index of the bin val is counted in.
+func (s Stats) binIndex(val Sample) int {
+	// TODO: use faster lookup method for large bin counts
+	for bin, binVal := range s.bins {
+		if val < binVal || val == binVal && s.interval == UpperInclusive {
+			return bin
+		}
+	}
+	// only values at or beyond the last bound, such as +Inf, get here
+	return len(s.bins) - 1
+}
+
+// Interval returns the convention for which end of each bin is closed.
+func (s Stats) Interval() BinInterval {
+	return s.interval
+}
+
+// CollapseBins returns a copy of s with every factor consecutive bins merged
+// into one, whose count is the sum of theirs and whose interval spans theirs,
+// for reporting a histogram at a coarser resolution. If the number of bins is
+// not a multiple of factor, the last bin merges the remainder. The first and
+// last bins remain unbounded below and above respectively. Per-bin sums and
+// retained samples are carried over. At least 3 bins must result.
+//
+// It may only be called after CreateBins.
+func (s Stats) CollapseBins(factor int) *Stats {
+	s.checkBins("CollapseBins")
+	if factor < 1 {
+		panic("factor must be positive")
+	}
+	n := (len(s.bins) + factor - 1) / factor
+	if n < 3 {
+		panic("Not enough bins")
+	}
+	c := s.Clone()
+	c.bins = make([]Sample, n)
+	c.binCounts = make([]int, n)
+	if s.binSums != nil {
+		c.binSums = make([]Sample, n)
+	}
+	for i := range s.bins {
+		j := i / factor
+		c.bins[j] = s.bins[i]
+		c.binCounts[j] += s.binCounts[i]
+		if s.binSums != nil {
+			c.binSums[j] += s.binSums[i]
+		}
+	}
+	return c
+}
+
+// Returns the count and low and high ends of the i'th bin.
+//
+// The bin interval is (low,high], or [low,high) if the bins were created with
+// the LowerInclusive interval; see Interval.
+func (s Stats) Bin(i int) (count int, low, high Sample) {
+	count = s.binCounts[i]
+	high = s.bins[i]
+	if i == 0 {
+		low = -math.MaxFloat64
+	} else {
+		low = s.bins[i-1]
+	}
+	return
+}
+
+// BinnedPercentile estimates the value at the given percentile from the bin
+// counts, assuming the samples in each bin are spread evenly across it. As
+// the edge bins are unbounded, percentiles falling in them are clamped to the
+// low or high value given to CreateBins, unless SetEdgeExtrapolation is on.
+//
+// After CreateBinsTrackSum the bin means are used to refine the estimate:
+// within each bin the samples are assumed to be spread evenly either side of
+// the bin mean, with just enough of them below it to give that mean. This is
+// considerably more accurate when the samples are bunched towards one end of
+// their bins.
+//
+// It may only be called after CreateBins.
+func (s Stats) BinnedPercentile(pct float64) Sample {
+	s.checkBins("BinnedPercentile")
+	return s.binnedPercentile(s.cumulativeBinCounts(), pct)
+}
+
+// BinnedQuantiles returns BinnedPercentile for each of pcts, computing the
+// cumulative bin counts only once.
+//
+// It may only be called after CreateBins.
+func (s Stats) BinnedQuantiles(pcts []float64) []Sample {
+	s.checkBins("BinnedQuantiles")
+	cum := s.cumulativeBinCounts()
+	q := make([]Sample, len(pcts))
+	for i, pct := range pcts {
+		q[i] = s.binnedPercentile(cum, pct)
+	}
+	return q
+}
+
+// EqualizationMap returns a function mapping sample values to their positions
+// in [0,1] under histogram equalization: the estimated fraction of the binned
+// samples at or below the value, so that mapped samples are spread roughly
+// evenly over [0,1]. Values up to the minimum sample map to 0, and from the
+// maximum on to 1.
+//
+// The fraction is estimated from the bin counts, interpolating linearly
+// within each bin as if its samples were spread evenly across it, with the
+// edge bins taken to end at the minimum and maximum sample values. The
+// function reflects the bins as they are when EqualizationMap is called, and
+// returns NaN if no samples had been binned.
+//
+// It may only be called after CreateBins.
+func (s Stats) EqualizationMap() func(Sample) float64 {
+	s.checkBins("EqualizationMap")
+	cum := s.cumulativeBinCounts()
+	total := float64(cum[len(cum)-1])
+	lows := make([]Sample, len(s.bins))
+	highs := make([]Sample, len(s.bins))
+	for i := range s.bins {
+		lows[i], highs[i] = s.binBounds(i)
+	}
+	bins := Stats{bins: append([]Sample(nil), s.bins...), interval: s.interval}
+	min, max := s.min, s.max
+	return func(val Sample) float64 {
+		switch {
+		case total == 0:
+			return math.NaN()
+		case val <= min:
+			return 0
+		case val >= max:
+			return 1
+		}
+		i := bins.binIndex(val)
+		below := 0
+		if i > 0 {
+			below = cum[i-1]
+		}
+		f := 1.0
+		if highs[i] > lows[i] {
+			f = math.Max(0, math.Min(1, float64((val-lows[i])/(highs[i]-lows[i]))))
+		}
+		return (float64(below) + f*float64(cum[i]-below)) / total
+	}
+}
+
+// cumulativeBinCounts returns the running totals of the bin counts.
+func (s Stats) cumulativeBinCounts() []int {
+	cum := make([]int, len(s.binCounts))
+	total := 0
+	for i, c := range s.binCounts {
+		total += c
+		cum[i] = total
+	}
+	return cum
+}
+
+func (s Stats) binnedPercentile(cum []int, pct float64) Sample {
+	if pct < 0 {
+		panic("pct too small")
+	}
+	if pct > 1 {
+		panic("pct too large")
+	}
+	total := cum[len(cum)-1]
+	if total == 0 {
+		return 0
+	}
+	target := pct * float64(total)
+	// first non-empty bin where the cumulative count reaches the target
+	i := sort.Search(len(cum), func(i int) bool {
+		return cum[i] > 0 && float64(cum[i]) >= target
+	})
+	below := 0
+	if i > 0 {
+		below = cum[i-1]
+	}
+	return s.interpolateBin(i, (target-float64(below))/float64(cum[i]-below))
+}
+
+// interpolateBin returns the value the fraction f of the way through the
+// samples of bin i.
+func (s Stats) interpolateBin(i int, f float64) Sample {
+	switch {
+	case (i == 0 || i == len(s.bins)-1) && s.extrapolate:
+		low, high := s.binBounds(i)
+		return low + Sample(f)*(high-low)
+	case i == 0:
+		return s.bins[0]
+	case i == len(s.bins)-1:
+		return s.bins[len(s.bins)-2]
+	}
+	low, high := s.bins[i-1], s.bins[i]
+	if s.binSums == nil {
+		return low + Sample(f)*(high-low)
+	}
+	// Spread the samples evenly over [low,mean] and [mean,high], with the
+	// fraction p in the lower piece chosen so that the overall mean is the bin
+	// mean: p(low+mean)/2 + (1-p)(mean+high)/2 = mean.
+	mean := Sample(math.Max(float64(low), math.Min(float64(high), s.BinMean(i))))
+	p := float64((high - mean) / (high - low))
+	switch {
+	case f < p:
+		return low + Sample(f/p)*(mean-low)
+	case p >= 1:
+		return mean
+	}
+	return mean + Sample((f-p)/(1-p))*(high-mean)
+}
+
+// BinMean returns the mean of the samples in the i'th bin, or NaN if the bin
+// is empty.
+//
+// It may only be called after CreateBinsTrackSum.
+func (s Stats) BinMean(i int) float64 {
+	if s.binSums == nil {
+		panic("BinMean() requires CreateBinsTrackSum()")
+	}
+	return float64(s.binSums[i]) / float64(s.binCounts[i])
+}
+
+// Returns the number of bins
+func (s Stats) NBins() int {
+	return len(s.bins)
+}
+
+// IsBinned reports whether CreateBins (or one of its variants) has been
+// called, so callers can choose between sample-based methods such as
+// Percentile and bin-based ones such as BinnedPercentile without recovering
+// from a panic.
+func (s Stats) IsBinned() bool {
+	return len(s.bins) > 0
+}
+
+// checkBins panics if CreateBins has not been called, so the named method
+// has no bins to work with.
+func (s Stats) checkBins(method string) {
+	if len(s.bins) == 0 {
+		panic("cannot call " + method + "() before CreateBins()")
+	}
+}
+
+// BelowRange returns the number of samples which fell below the low end of the
+// range given to CreateBins (or at it, for UpperInclusive bins), i.e. the count
+// of the first bin.
+func (s Stats) BelowRange() int {
+	s.checkBins("BelowRange")
+	return s.binCounts[0]
+}
+
+// AboveRange returns the number of samples which fell above the high end of
+// the range given to CreateBins (or at it, for LowerInclusive bins), i.e. the
+// count of the last bin.
+func (s Stats) AboveRange() int {
+	s.checkBins("AboveRange")
+	return s.binCounts[len(s.binCounts)-1]
+}
+
+// BinCoverage returns the fraction of the binned samples which fell inside the
+// range given to CreateBins rather than in the edge bins. A value well below
+// 1 suggests the range was poorly chosen. It is NaN if no samples have been
+// binned.
+func (s Stats) BinCoverage() float64 {
+	s.checkBins("BinCoverage")
+	total := 0
+	for _, c := range s.binCounts {
+		total += c
+	}
+	inside := total - s.binCounts[0] - s.binCounts[len(s.binCounts)-1]
+	return float64(inside) / float64(total)
+}
+
+// BinFractions returns the fraction of the binned samples in each bin, which
+// sum to 1 up to rounding. They are NaN if no samples have been binned.
+//
+// It may only be called after CreateBins.
+func (s Stats) BinFractions() []float64 {
+	s.checkBins("BinFractions")
+	return s.smoothedBinFractions(0)
+}
+
+// binBounds returns the finite extent of bin i, taking the edge bins to end at
+// the minimum and maximum sample values.
+func (s Stats) binBounds(i int) (low, high Sample) {
+	_, low, high = s.Bin(i)
+	if i == 0 {
+		low = high
+		if s.min < low {
+			low = s.min
+		}
+	}
+	if i == len(s.bins)-1 {
+		high = low
+		if s.max > high {
+			high = s.max
+		}
+	}
+	return low, high
+}
+
+// ProbabilityInRange estimates the fraction of the binned samples in
+// [low,high] from the bin counts. Bins entirely inside the range contribute
+// all their samples and those partially inside contribute in proportion to
+// the overlap, assuming each bin's samples are spread evenly across it. The
+// edge bins are taken to end at the minimum and maximum sample values. The
+// result is NaN if no samples have been binned.
+//
+// It may only be called after CreateBins.
+func (s Stats) ProbabilityInRange(low, high Sample) float64 {
+	s.checkBins("ProbabilityInRange")
+	if high < low {
+		panic("high must not be less than low")
+	}
+	var in float64
+	total := 0
+	for i, c := range s.binCounts {
+		total += c
+		if c == 0 {
+			continue
+		}
+		bl, bh := s.binBounds(i)
+		if bl == bh {
+			if low <= bl && bl <= high {
+				in += float64(c)
+			}
+			continue
+		}
+		ol, oh := bl, bh
+		if low > ol {
+			ol = low
+		}
+		if high < oh {
+			oh = high
+		}
+		if oh > ol {
+			in += float64(c) * float64(oh-ol) / float64(bh-bl)
+		}
+	}
+	return in / float64(total)
+}
+
+// MedianBin returns the index of the bin containing the median according to
+// the histogram: the first bin at which the cumulative count reaches half the
+// binned samples. This needs only the bin counts, unlike Median, which is
+// exact but needs the samples. It returns -1 if no samples have been binned.
+//
+// It may only be called after CreateBins.
+func (s Stats) MedianBin() int {
+	s.checkBins("MedianBin")
+	cum := s.cumulativeBinCounts()
+	total := cum[len(cum)-1]
+	if total == 0 {
+		return -1
+	}
+	return sort.Search(len(cum), func(i int) bool { return 2*cum[i] >= total })
+}
+
+// DensestBin returns the index of the bin with the most samples per unit
+// width, which need not be the bin with the most samples when bins differ in
+// width. The edge bins are taken to end at the minimum and maximum sample
+// values; if that leaves one with zero width, it is given the width of its
+// finite neighbour. Ties go to the lowest bin. It returns -1 if no samples
+// have been binned.
+//
+// It may only be called after CreateBins.
+func (s Stats) DensestBin() int {
+	s.checkBins("DensestBin")
+	best, bestDensity := -1, 0.0
+	for i, c := range s.binCounts {
+		if c == 0 {
+			continue
+		}
+		low, high := s.binBounds(i)
+		if low == high {
+			neighbour := 1
+			if i == len(s.bins)-1 {
+				neighbour = i - 1
+			}
+			low, high = s.binBounds(neighbour)
+		}
+		if density := float64(c) / float64(high-low); density > bestDensity {
+			best, bestDensity = i, density
+		}
+	}
+	return best
+}
+
+// ModalInterval returns the bounds and count of the bin with the most
+// samples, the most likely interval for continuous data whose exact mode is
+// meaningless. The bounds are as for Bin, so an edge bin extends to
+// ±math.MaxFloat64. Ties go to the lowest bin. Raw counts are compared, so an
+// edge bin covering a wide range may win; see DensestBin for a comparison by
+// density. It returns (0, 0, 0) if no samples have been binned.
+//
+// It may only be called after CreateBins.
+func (s Stats) ModalInterval() (low, high Sample, count int) {
+	s.checkBins("ModalInterval")
+	best := 0
+	for i, c := range s.binCounts {
+		if c > s.binCounts[best] {
+			best = i
+		}
+	}
+	if s.binCounts[best] == 0 {
+		return 0, 0, 0
+	}
+	count, low, high = s.Bin(best)
+	return low, high, count
 }
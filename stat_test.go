@@ -6,6 +6,8 @@ package summstat
 
 import (
 	"math"
+	"math/rand"
+	"runtime"
 	"testing"
 )
 
@@ -109,6 +111,15 @@ var (
 	}
 )
 
+func expectPanic(t *testing.T, name string, f func()) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s did not panic", name)
+		}
+	}()
+	f()
+}
+
 func insertSamples(s *Stats, samples []Sample) {
 	for _, sample := range samples {
 		s.AddSample(sample)
@@ -285,3 +296,1852 @@ func TestDiscardBins(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateBinsIQR(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{-500, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 400})
+	// Q1 = 3, Q3 = 8 (nearest ranks 3 and 8 of 0..11), IQR = 5
+	s.CreateBinsIQR(6, 1.5)
+	exp := []Sample{-4.5, 0.5, 5.5, 10.5, 15.5, math.MaxFloat64}
+	if len(s.bins) != len(exp) {
+		t.Fatalf("len(s.bins) = %d, expected %d", len(s.bins), len(exp))
+	}
+	for i, bin := range s.bins {
+		if bin != exp[i] {
+			t.Errorf("s.bins[%d] = %v, expected %v", i, bin, exp[i])
+		}
+	}
+
+	s = NewStats()
+	insertSamples(s, []Sample{1, 2, 3})
+	expectPanic(t, "CreateBinsIQR with 3 samples", func() { s.CreateBinsIQR(5, 1.5) })
+}
+
+func TestWinsorized(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{-1000, 1, 2, 3, 4, 5, 6, 7, 8, 1000})
+	w := s.Winsorized(0.1)
+	exp := []Sample{1, 1, 2, 3, 4, 5, 6, 7, 8, 8}
+	if len(w) != len(exp) {
+		t.Fatalf("len(w) = %d, expected %d", len(w), len(exp))
+	}
+	for i := range exp {
+		if w[i] != exp[i] {
+			t.Errorf("w[%d] = %v, expected %v", i, w[i], exp[i])
+		}
+	}
+	if s.Min() != -1000 || s.Max() != 1000 {
+		t.Errorf("Winsorized modified the stats: min %v, max %v", s.Min(), s.Max())
+	}
+
+	// positions are kept and the samples are not reordered
+	samples := []Sample{50, 1, 100, 2, 3, 4, 5, 6, 7, -20}
+	s = NewStats()
+	insertSamples(s, samples)
+	w = s.Winsorized(0.1)
+	exp = []Sample{50, 1, 50, 2, 3, 4, 5, 6, 7, 1}
+	for i := range exp {
+		if w[i] != exp[i] {
+			t.Errorf("w[%d] = %v, expected %v", i, w[i], exp[i])
+		}
+		if s.samples[i] != samples[i] {
+			t.Errorf("Winsorized reordered the samples: %v", s.samples)
+			break
+		}
+	}
+
+	s.CreateBins(3, 1, 8)
+	expectPanic(t, "Winsorized after CreateBins", func() { s.Winsorized(0.1) })
+}
+
+func TestRobustRange(t *testing.T) {
+	s := NewStats()
+	for i := 1; i <= 100; i++ {
+		s.AddSample(Sample(i))
+	}
+	s.AddSample(-1e6)
+	s.AddSample(1e6)
+	low, high := s.RobustRange(0.05)
+	if low != 5 || high != 96 {
+		t.Errorf("RobustRange(0.05) = (%v, %v), expected (5, 96)", low, high)
+	}
+}
+
+func TestLogReciprocalMeans(t *testing.T) {
+	s := NewStats(TrackLogReciprocal())
+	insertSamples(s, []Sample{1, 2, 4})
+	if math.Abs(s.GeometricMean()-2) > 1e-12 {
+		t.Errorf("GeometricMean() = %v, expected 2", s.GeometricMean())
+	}
+	if math.Abs(s.HarmonicMean()-12.0/7) > 1e-12 {
+		t.Errorf("HarmonicMean() = %v, expected %v", s.HarmonicMean(), 12.0/7)
+	}
+
+	s = NewStats()
+	insertSamples(s, []Sample{1, 2, 4})
+	expectPanic(t, "GeometricMean without tracking", func() { s.GeometricMean() })
+	expectPanic(t, "HarmonicMean without tracking", func() { s.HarmonicMean() })
+}
+
+func TestCommonPercentiles(t *testing.T) {
+	s := NewStats()
+	for i := 1000; i >= 0; i-- {
+		s.AddSample(Sample(i))
+	}
+	exp := map[string]Sample{
+		"p50":  500,
+		"p90":  900,
+		"p95":  950,
+		"p99":  990,
+		"p999": 999,
+	}
+	m := s.CommonPercentiles()
+	if len(m) != len(exp) {
+		t.Errorf("len(CommonPercentiles()) = %d, expected %d", len(m), len(exp))
+	}
+	for k, v := range exp {
+		if got, ok := m[k]; !ok || got != v {
+			t.Errorf("CommonPercentiles()[%q] = %v, expected %v", k, got, v)
+		}
+	}
+}
+
+func TestDistinctCount(t *testing.T) {
+	s := NewStats()
+	if s.DistinctCount() != 0 {
+		t.Errorf("DistinctCount() = %d, expected 0", s.DistinctCount())
+	}
+	insertSamples(s, []Sample{2, 1, 3, 2, 1, 2})
+	if s.DistinctCount() != 3 {
+		t.Errorf("DistinctCount() = %d, expected 3", s.DistinctCount())
+	}
+}
+
+func TestQuartileCoeffDispersion(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{9, 1, 8, 2, 7, 3, 6, 4, 5})
+	// Q1 = 3, Q3 = 7
+	if got := s.QuartileCoeffDispersion(); math.Abs(got-0.4) > 1e-15 {
+		t.Errorf("QuartileCoeffDispersion() = %v, expected 0.4", got)
+	}
+
+	s = NewStats()
+	insertSamples(s, []Sample{-1, -1, 1, 1})
+	if got := s.QuartileCoeffDispersion(); got != 0 {
+		t.Errorf("QuartileCoeffDispersion() = %v, expected 0", got)
+	}
+}
+
+func TestOrderStatistic(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{5, 1, 3, 2, 4})
+	for k := 0; k < 5; k++ {
+		if got := s.OrderStatistic(k); got != Sample(k+1) {
+			t.Errorf("OrderStatistic(%d) = %v, expected %v", k, got, k+1)
+		}
+	}
+	expectPanic(t, "OrderStatistic(-1)", func() { s.OrderStatistic(-1) })
+	expectPanic(t, "OrderStatistic(5)", func() { s.OrderStatistic(5) })
+}
+
+func TestPercentileExcel(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{10, 9, 8, 7, 6, 5, 4, 3, 2, 1})
+	for _, test := range []struct {
+		pct float64
+		exp Sample
+	}{
+		{0, 1},
+		{.3, 3.7},
+		{.9, 9.1},
+		{1, 10},
+	} {
+		if got := s.PercentileExcel(test.pct); math.Abs(float64(got-test.exp)) > 1e-12 {
+			t.Errorf("PercentileExcel(%v) = %v, expected %v", test.pct, got, test.exp)
+		}
+	}
+}
+
+func TestRangeCounts(t *testing.T) {
+	s := NewStats()
+	expectPanic(t, "BelowRange before CreateBins", func() { s.BelowRange() })
+	s.CreateBins(4, 0, 10)
+	insertSamples(s, []Sample{-5, -1, 0, 3, 7, 10, 11, 12, 100})
+	if c, _, _ := s.Bin(0); s.BelowRange() != c || c != 3 {
+		t.Errorf("BelowRange() = %d, first bin count %d, expected 3", s.BelowRange(), c)
+	}
+	if c, _, _ := s.Bin(s.NBins() - 1); s.AboveRange() != c || c != 3 {
+		t.Errorf("AboveRange() = %d, last bin count %d, expected 3", s.AboveRange(), c)
+	}
+}
+
+func TestClone(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{3, 1, 2})
+	c := s.Clone()
+	s.AddSample(10)
+	if c.Count() != 3 || c.Max() != 3 || len(c.samples) != 3 {
+		t.Errorf("clone changed: count %d, max %v, %d samples", c.Count(), c.Max(), len(c.samples))
+	}
+	if c.Median() != 2 {
+		t.Errorf("clone Median() = %v, expected 2", c.Median())
+	}
+}
+
+func TestCloneBins(t *testing.T) {
+	s := NewStats()
+	s.CreateBins(5, 0, 3)
+	insertSamples(s, []Sample{-1, 0.5, 1.5, 2.5, 4})
+	c := s.Clone()
+	insertSamples(s, []Sample{-1, 0.5, 1.5, 1.5, 2.5, 4})
+	if c.NBins() != 5 {
+		t.Fatalf("clone NBins() = %d, expected 5", c.NBins())
+	}
+	for i := 0; i < c.NBins(); i++ {
+		count, _, _ := c.Bin(i)
+		if count != 1 {
+			t.Errorf("clone bin %d count = %d, expected 1", i, count)
+		}
+	}
+	if count, _, _ := s.Bin(2); count != 3 {
+		t.Errorf("original bin 2 count = %d, expected 3", count)
+	}
+}
+
+func TestCentralSpread(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{0, 1, 10, 25, 100})
+	iqr := s.Percentile(.75) - s.Percentile(.25)
+	if got := s.CentralSpread(0.5); got != iqr || got != 24 {
+		t.Errorf("CentralSpread(0.5) = %v, expected IQR %v", got, iqr)
+	}
+	if got := s.CentralSpread(1); got != s.Spread() {
+		t.Errorf("CentralSpread(1) = %v, expected %v", got, s.Spread())
+	}
+}
+
+func TestPercentileDetail(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{0, 1, 10, 25, 100, 3})
+	for _, pct := range []float64{0, .1, .25, .3, .5, .7, .9, 1} {
+		value, rankFrac, index := s.PercentileDetail(pct)
+		if rankFrac != 5*pct {
+			t.Errorf("PercentileDetail(%v) rank = %v, expected %v", pct, rankFrac, 5*pct)
+		}
+		if exp := int(5*pct + 0.5); index != exp {
+			t.Errorf("PercentileDetail(%v) index = %d, expected %d", pct, index, exp)
+		}
+		if value != s.Percentile(pct) {
+			t.Errorf("PercentileDetail(%v) value = %v, expected %v", pct, value, s.Percentile(pct))
+		}
+	}
+
+	// the index follows the rank rounding mode; sorted samples are
+	// 0, 1, 3, 10, 25, 100
+	for _, test := range []struct {
+		mode  RankRounding
+		pct   float64
+		index int
+		value Sample
+	}{
+		{Floor, .5, 2, 3},
+		{Ceil, .5, 3, 10},
+		{RoundHalfEven, .5, 2, 3},
+		{RoundHalfEven, .3, 2, 3},
+		{Floor, .3, 1, 1},
+	} {
+		s.SetRankRounding(test.mode)
+		value, rankFrac, index := s.PercentileDetail(test.pct)
+		if rankFrac != 5*test.pct || index != test.index || value != test.value {
+			t.Errorf("mode %d PercentileDetail(%v) = (%v, %v, %d), expected (%v, %v, %d)",
+				test.mode, test.pct, value, rankFrac, index, test.value, 5*test.pct, test.index)
+		}
+	}
+}
+
+func TestFilterStats(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{-3, 1, -2, 2, -1, 3, 0})
+	f := s.FilterStats(func(val Sample) bool { return val > 0 })
+	if f.Count() != 3 || f.Min() != 1 || f.Max() != 3 || f.Mean() != 2 || f.Median() != 2 {
+		t.Errorf("positive stats: count %d, min %v, max %v, mean %v, median %v",
+			f.Count(), f.Min(), f.Max(), f.Mean(), f.Median())
+	}
+	if s.Count() != 7 {
+		t.Errorf("FilterStats modified the original: count %d", s.Count())
+	}
+}
+
+func TestSigmaBand(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{8, 12}) // mean 10, stddev 2
+	for _, test := range []struct {
+		val  Sample
+		band int
+	}{
+		{11, 0}, // 0.5σ
+		{7, 1},  // 1.5σ
+		{15, 2}, // 2.5σ
+		{18, 3}, // 4σ
+		{2, 3},  // 4σ
+	} {
+		if got := s.SigmaBand(test.val); got != test.band {
+			t.Errorf("SigmaBand(%v) = %d, expected %d", test.val, got, test.band)
+		}
+	}
+
+	s = NewStats()
+	insertSamples(s, []Sample{5, 5})
+	if s.SigmaBand(5) != 0 || s.SigmaBand(6) != 3 {
+		t.Errorf("zero stddev: SigmaBand(5) = %d, SigmaBand(6) = %d", s.SigmaBand(5), s.SigmaBand(6))
+	}
+}
+
+func TestPercentileBracket(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{4, 3, 2, 1})
+	for _, test := range []struct {
+		pct          float64
+		lower, upper Sample
+	}{
+		{0, 1, 1},
+		{.5, 2, 3},
+		{2.0 / 3, 3, 3},
+		{.9, 3, 4},
+		{1, 4, 4},
+	} {
+		lower, upper := s.PercentileBracket(test.pct)
+		if lower != test.lower || upper != test.upper {
+			t.Errorf("PercentileBracket(%v) = (%v, %v), expected (%v, %v)",
+				test.pct, lower, upper, test.lower, test.upper)
+		}
+	}
+}
+
+func TestBinMean(t *testing.T) {
+	s := NewStats()
+	s.CreateBins(4, 0, 10)
+	expectPanic(t, "BinMean without CreateBinsTrackSum", func() { s.BinMean(1) })
+
+	s.CreateBinsTrackSum(4, 0, 10)
+	insertSamples(s, []Sample{-4, -2, 1, 2, 6, 20})
+	for i, exp := range []float64{-3, 1.5, 6, 20} {
+		if got := s.BinMean(i); got != exp {
+			t.Errorf("BinMean(%d) = %v, expected %v", i, got, exp)
+		}
+	}
+	s.CreateBinsTrackSum(4, 0, 10)
+	if got := s.BinMean(1); !math.IsNaN(got) {
+		t.Errorf("BinMean of an empty bin = %v, expected NaN", got)
+	}
+}
+
+func TestState(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{1, 2})
+	st := s.State()
+	if st.Count != 2 || st.Sum != 3 || st.Sum2 != 5 || st.Min != 1 || st.Max != 2 || st.NumSamples != 2 {
+		t.Errorf("State() = %+v", st)
+	}
+	if st.Bins != nil || st.BinCounts != nil {
+		t.Errorf("State() has bins before CreateBins: %+v", st)
+	}
+
+	s.CreateBins(3, 0, 10)
+	insertSamples(s, []Sample{5, 20})
+	st = s.State()
+	if st.Count != 4 || st.Sum != 28 || st.Max != 20 || st.NumSamples != 0 {
+		t.Errorf("State() = %+v", st)
+	}
+	if len(st.Bins) != 3 || st.Bins[0] != 0 || st.Bins[1] != 10 || st.Bins[2] != math.MaxFloat64 {
+		t.Errorf("State().Bins = %v", st.Bins)
+	}
+	if len(st.BinCounts) != 3 || st.BinCounts[0] != 0 || st.BinCounts[1] != 1 || st.BinCounts[2] != 1 {
+		t.Errorf("State().BinCounts = %v", st.BinCounts)
+	}
+	st.Bins[1] = 100
+	st.BinCounts[1] = 100
+	if count, _, high := s.Bin(1); count != 1 || high != 10 {
+		t.Errorf("modifying State() changed bin 1 to count %d, high %v", count, high)
+	}
+}
+
+func TestRankRounding(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{0, 10, 20, 30, 40, 50})
+	// ranks are 5*pct: 0.5 gives 2.5 and 0.44 gives 2.2
+	for _, test := range []struct {
+		mode     RankRounding
+		half     Sample
+		fraction Sample
+	}{
+		{RoundHalfUp, 30, 20},
+		{Floor, 20, 20},
+		{Ceil, 30, 30},
+		{RoundHalfEven, 20, 20},
+	} {
+		s.SetRankRounding(test.mode)
+		chkPct(t, s, .5, test.half)
+		chkPct(t, s, .44, test.fraction)
+	}
+	s.SetRankRounding(RoundHalfEven)
+	chkPct(t, s, .7, 40) // rank 3.5
+}
+
+func TestApply(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{1, 2, 3, 4})
+	product := s.Apply(func(acc, val Sample) Sample { return acc * val }, 1)
+	if product != 24 {
+		t.Errorf("product = %v, expected 24", product)
+	}
+	s.CreateBins(3, 0, 1)
+	expectPanic(t, "Apply after CreateBins", func() {
+		s.Apply(func(acc, val Sample) Sample { return acc }, 0)
+	})
+}
+
+func TestPercentileType(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{20, 13, 12, 9, 7, 4, 3, 1})
+	// quantile(c(1, 3, 4, 7, 9, 12, 13, 20), c(0, 0.3, 0.75, 1), type=...) in R
+	for _, test := range []struct {
+		hfType int
+		exp    []Sample
+	}{
+		{1, []Sample{1, 4, 12, 20}},
+		{4, []Sample{1, 3.4, 12, 20}},
+		{6, []Sample{1, 3.7, 12.75, 20}},
+		{7, []Sample{1, 4.3, 12.25, 20}},
+		{8, []Sample{1, 3.8333333333333333, 12.583333333333333, 20}},
+	} {
+		for i, pct := range []float64{0, .3, .75, 1} {
+			if got := s.PercentileType(pct, test.hfType); math.Abs(float64(got-test.exp[i])) > 1e-12 {
+				t.Errorf("PercentileType(%v, %d) = %v, expected %v", pct, test.hfType, got, test.exp[i])
+			}
+		}
+	}
+	expectPanic(t, "PercentileType(.5, 2)", func() { s.PercentileType(.5, 2) })
+}
+
+func TestBinnedPercentile(t *testing.T) {
+	s := NewStats()
+	s.CreateBins(12, 0, 10)
+	for i := 0; i < 100; i++ {
+		s.AddSample((Sample(i) + 0.5) / 10)
+	}
+	for _, test := range []struct {
+		pct float64
+		exp Sample
+	}{
+		{0, 0},
+		{.25, 2.5},
+		{.5, 5},
+		{.95, 9.5},
+		{1, 10},
+	} {
+		if got := s.BinnedPercentile(test.pct); math.Abs(float64(got-test.exp)) > 1e-9 {
+			t.Errorf("BinnedPercentile(%v) = %v, expected %v", test.pct, got, test.exp)
+		}
+	}
+	// percentiles in the edge bins are clamped to the range
+	s.AddSample(-50)
+	s.AddSample(50)
+	if got := s.BinnedPercentile(0); got != 0 {
+		t.Errorf("BinnedPercentile(0) = %v, expected 0", got)
+	}
+	if got := s.BinnedPercentile(1); got != 10 {
+		t.Errorf("BinnedPercentile(1) = %v, expected 10", got)
+	}
+}
+
+func TestBinnedPercentileTrackSum(t *testing.T) {
+	// evenly spread samples give the same estimates as without the sums
+	uniform, tracked := NewStats(), NewStats()
+	uniform.CreateBins(12, 0, 10)
+	tracked.CreateBinsTrackSum(12, 0, 10)
+	for i := 0; i < 100; i++ {
+		uniform.AddSample((Sample(i) + 0.5) / 10)
+		tracked.AddSample((Sample(i) + 0.5) / 10)
+	}
+	for _, pct := range []float64{.1, .25, .5, .73, .95} {
+		if got, exp := tracked.BinnedPercentile(pct), uniform.BinnedPercentile(pct); math.Abs(float64(got-exp)) > 1e-9 {
+			t.Errorf("uniform BinnedPercentile(%v) = %v, expected %v", pct, got, exp)
+		}
+	}
+
+	// 10u² for evenly spread u is bunched towards the low end of each bin
+	uniform, tracked = NewStats(), NewStats()
+	uniform.CreateBins(7, 0, 10)
+	tracked.CreateBinsTrackSum(7, 0, 10)
+	const n = 1000
+	for i := 0; i < n; i++ {
+		u := (Sample(i) + 0.5) / n
+		uniform.AddSample(10 * u * u)
+		tracked.AddSample(10 * u * u)
+	}
+	var uniformErr, trackedErr float64
+	for pct := 0.05; pct < 1; pct += 0.05 {
+		exact := 10 * pct * pct
+		uniformErr += math.Abs(float64(uniform.BinnedPercentile(pct)) - exact)
+		trackedErr += math.Abs(float64(tracked.BinnedPercentile(pct)) - exact)
+	}
+	if trackedErr >= uniformErr/2 {
+		t.Errorf("total error with bin sums %v, expected well under %v without", trackedErr, uniformErr)
+	}
+}
+
+func TestBinnedQuantiles(t *testing.T) {
+	s := NewStats()
+	s.CreateBins(7, -1, 4)
+	insertSamples(s, []Sample{-3, -0.5, 0.2, 0.4, 1.1, 1.3, 1.9, 2.5, 3.5, 9})
+	pcts := []float64{.99, 0, .1, .33, .5, .5, .75, 1}
+	q := s.BinnedQuantiles(pcts)
+	if len(q) != len(pcts) {
+		t.Fatalf("len(BinnedQuantiles()) = %d, expected %d", len(q), len(pcts))
+	}
+	for i, pct := range pcts {
+		if exp := s.BinnedPercentile(pct); q[i] != exp {
+			t.Errorf("BinnedQuantiles()[%d] = %v, expected BinnedPercentile(%v) = %v", i, q[i], pct, exp)
+		}
+	}
+}
+
+func TestRebinReset(t *testing.T) {
+	s := NewStats()
+	expectPanic(t, "RebinReset before CreateBins", func() { s.RebinReset(3, 0, 1) })
+	s.CreateBins(3, 0, 10)
+	insertSamples(s, []Sample{1, 2, 3, 20})
+	s.RebinReset(5, 0, 30)
+	if s.Count() != 4 || s.Mean() != 6.5 || s.Min() != 1 || s.Max() != 20 {
+		t.Errorf("scalar stats lost: count %d, mean %v, min %v, max %v", s.Count(), s.Mean(), s.Min(), s.Max())
+	}
+	if s.NBins() != 5 {
+		t.Errorf("NBins() = %d, expected 5", s.NBins())
+	}
+	for i := 0; i < s.NBins(); i++ {
+		if count, _, _ := s.Bin(i); count != 0 {
+			t.Errorf("bin %d count = %d, expected 0", i, count)
+		}
+	}
+	s.AddSample(15)
+	if count, low, high := s.Bin(2); count != 1 || low != 10 || high != 20 {
+		t.Errorf("Bin(2) = (%d, %v, %v), expected (1, 10, 20)", count, low, high)
+	}
+}
+
+func TestFirstLast(t *testing.T) {
+	s := NewStats()
+	if s.First() != 0 || s.Last() != 0 {
+		t.Errorf("empty First() = %v, Last() = %v", s.First(), s.Last())
+	}
+	s.CreateBins(3, 0, 1)
+	for _, val := range []Sample{3, 1, 4, 1, 5} {
+		s.AddSample(val)
+		if s.First() != 3 {
+			t.Errorf("First() = %v, expected 3", s.First())
+		}
+		if s.Last() != val {
+			t.Errorf("Last() = %v, expected %v", s.Last(), val)
+		}
+	}
+}
+
+func TestDeltas(t *testing.T) {
+	s := NewStats(TrackDeltas())
+	insertSamples(s, []Sample{1, 3, 6, 10})
+	d := s.Deltas()
+	if d.Count() != 3 || d.Mean() != 3 || d.Min() != 2 || d.Max() != 4 {
+		t.Errorf("Deltas(): count %d, mean %v, min %v, max %v", d.Count(), d.Mean(), d.Min(), d.Max())
+	}
+	c := s.Clone()
+	s.AddSample(20)
+	if c.Deltas().Count() != 3 {
+		t.Errorf("clone Deltas().Count() = %d, expected 3", c.Deltas().Count())
+	}
+
+	// binning bins the deltas and stops retaining them, unless samples are kept
+	s = NewStats(TrackDeltas())
+	s.CreateBinsKeepSamples(5, 0, 3)
+	insertSamples(s, []Sample{1, 3, 2})
+	s.RebinReset(8, 0, 6)
+	s.AddSample(6)
+	d = s.Deltas()
+	if d.NBins() != 8 || len(d.samples) != 3 || d.Median() != 2 {
+		t.Errorf("kept Deltas(): %d bins, %d samples, median %v, expected 8, 3, 2", d.NBins(), len(d.samples), d.Median())
+	}
+	s = NewStats(TrackDeltas())
+	for i := 0; i < 1000; i++ {
+		s.AddSample(Sample(i % 10))
+	}
+	s.CreateBins(12, 0, 10)
+	s.AddSample(0)
+	s.AddSample(4)
+	d = s.Deltas()
+	if len(d.samples) != 0 || d.Count() != 1001 || d.NBins() != 12 || d.Max() != 4 {
+		t.Errorf("binned Deltas(): %d samples, count %d, %d bins, max %v, expected 0, 1001, 12, 4",
+			len(d.samples), d.Count(), d.NBins(), d.Max())
+	}
+	// the bins span ±10, so 4 lands in (3.33, 5]
+	if count, low, high := d.Bin(7); count != 1 || low >= 4 || high < 4 {
+		t.Errorf("Deltas().Bin(7) = %d, (%v, %v], expected to hold the delta 4", count, low, high)
+	}
+
+	expectPanic(t, "Deltas without tracking", func() { NewStats().Deltas() })
+}
+
+func TestBinCoverage(t *testing.T) {
+	samples := make([]Sample, 100)
+	for i := range samples {
+		samples[i] = Sample(i) + 0.5
+	}
+	tight, wide := NewStats(), NewStats()
+	tight.CreateBins(10, 40, 60)
+	wide.CreateBins(10, 0, 100)
+	insertSamples(tight, samples)
+	insertSamples(wide, samples)
+	if got := tight.BinCoverage(); got != 0.2 {
+		t.Errorf("tight BinCoverage() = %v, expected 0.2", got)
+	}
+	if got := wide.BinCoverage(); got != 1 {
+		t.Errorf("wide BinCoverage() = %v, expected 1", got)
+	}
+}
+
+func TestMeanAbsoluteDeviation(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{2, 2, 3, 4, 14})
+	// mean 5, deviations 3, 3, 2, 1, 9
+	if got := s.MeanAbsoluteDeviation(); got != 3.6 {
+		t.Errorf("MeanAbsoluteDeviation() = %v, expected 3.6", got)
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := NewStats()
+	for i := 0; i < 10000; i++ {
+		s.AddSample(Sample(r.ExpFloat64()))
+	}
+	d := s.Downsample(100)
+	if len(d) != 100 {
+		t.Fatalf("len(Downsample(100)) = %d, expected 100", len(d))
+	}
+	ds := NewStats()
+	insertSamples(ds, d)
+	for _, pct := range []float64{.1, .25, .5, .75, .9} {
+		exp, got := s.Percentile(pct), ds.Percentile(pct)
+		if math.Abs(float64(got-exp)) > 0.01+0.05*float64(exp) {
+			t.Errorf("downsampled Percentile(%v) = %v, expected about %v", pct, got, exp)
+		}
+	}
+}
+
+func TestPercentileIgnoreNaN(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{25, 1, Sample(math.NaN()), 100, 0, 10})
+	for _, test := range []struct {
+		pct float64
+		exp Sample
+	}{
+		{0, 0},
+		{.25, 1},
+		{.5, 10},
+		{.75, 25},
+		{1, 100},
+	} {
+		if got := s.PercentileIgnoreNaN(test.pct); got != test.exp {
+			t.Errorf("PercentileIgnoreNaN(%v) = %v, expected %v", test.pct, got, test.exp)
+		}
+	}
+}
+
+func TestWeightedExpectation(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{1, 2, 3, 4, 5})
+	got := s.WeightedExpectation(func(x Sample) float64 { return float64(x * x) })
+	if exp := float64(s.sum2) / float64(s.count); got != exp {
+		t.Errorf("WeightedExpectation(x²) = %v, expected %v", got, exp)
+	}
+	got = s.WeightedExpectation(func(x Sample) float64 { return float64(x) })
+	if got != s.Mean() {
+		t.Errorf("WeightedExpectation(x) = %v, expected %v", got, s.Mean())
+	}
+}
+
+func TestBinInterval(t *testing.T) {
+	upper, lower := NewStats(), NewStats()
+	upper.CreateBins(4, 0, 10)
+	lower.CreateBinsInterval(4, 0, 10, LowerInclusive)
+	if upper.Interval() != UpperInclusive || lower.Interval() != LowerInclusive {
+		t.Errorf("Interval() = %v and %v", upper.Interval(), lower.Interval())
+	}
+	for _, val := range []Sample{-1, 0, 3, 5, 7, 10, 20, Sample(math.Inf(1))} {
+		upper.AddSample(val)
+		lower.AddSample(val)
+	}
+	// upper: (-Inf,0] (0,5] (5,10] (10,+Inf)
+	// lower: (-Inf,0) [0,5) [5,10) [10,+Inf)
+	for i, exp := range [][2]int{{2, 1}, {2, 2}, {2, 2}, {2, 3}} {
+		u, _, _ := upper.Bin(i)
+		l, _, _ := lower.Bin(i)
+		if u != exp[0] || l != exp[1] {
+			t.Errorf("bin %d counts = (%d, %d), expected (%d, %d)", i, u, l, exp[0], exp[1])
+		}
+	}
+	// a sample exactly on a boundary lands in different bins
+	upper.RebinReset(4, 0, 10)
+	lower.RebinReset(4, 0, 10)
+	upper.AddSample(5)
+	lower.AddSample(5)
+	if c, _, _ := upper.Bin(1); c != 1 {
+		t.Errorf("upper-inclusive 5 not in bin 1")
+	}
+	if c, _, _ := lower.Bin(2); c != 1 {
+		t.Errorf("lower-inclusive 5 not in bin 2")
+	}
+}
+
+func TestSecondMinMax(t *testing.T) {
+	s := NewStats()
+	s.AddSample(5)
+	if s.SecondMin() != 0 || s.SecondMax() != 0 {
+		t.Errorf("one sample: SecondMin() = %v, SecondMax() = %v", s.SecondMin(), s.SecondMax())
+	}
+	insertSamples(s, []Sample{1, 3, 9, 2})
+	if s.SecondMin() != 2 || s.SecondMax() != 5 {
+		t.Errorf("SecondMin() = %v, SecondMax() = %v, expected 2 and 5", s.SecondMin(), s.SecondMax())
+	}
+	insertSamples(s, []Sample{1, 9})
+	if s.SecondMin() != 1 || s.SecondMax() != 9 {
+		t.Errorf("repeated extremes: SecondMin() = %v, SecondMax() = %v", s.SecondMin(), s.SecondMax())
+	}
+}
+
+func TestFractionWithinSigma(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := NewStats()
+	for i := 0; i < 10000; i++ {
+		s.AddSample(Sample(100 + 15*r.NormFloat64()))
+	}
+	for _, test := range []struct {
+		k, exp float64
+	}{
+		{1, 0.683},
+		{2, 0.954},
+		{3, 0.997},
+	} {
+		if got := s.FractionWithinSigma(test.k); math.Abs(got-test.exp) > 0.01 {
+			t.Errorf("FractionWithinSigma(%v) = %v, expected about %v", test.k, got, test.exp)
+		}
+	}
+}
+
+func TestCentralMoment(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{2, 4, 4, 4, 5, 5, 7, 9})
+	if got := s.CentralMoment(2); math.Abs(got-s.Stddev()*s.Stddev()) > 1e-12 || got != 4 {
+		t.Errorf("CentralMoment(2) = %v, expected variance 4", got)
+	}
+	// the deviations from the mean of 5 are -3, -1, -1, -1, 0, 0, 2, 4
+	if got := s.CentralMoment(3); got != 5.25 {
+		t.Errorf("CentralMoment(3) = %v, expected 5.25", got)
+	}
+	skewness := s.CentralMoment(3) / math.Pow(s.CentralMoment(2), 1.5)
+	if math.Abs(skewness-0.65625) > 1e-12 {
+		t.Errorf("skewness = %v, expected 0.65625", skewness)
+	}
+	if s.CentralMoment(0) != 1 || s.CentralMoment(1) != 0 {
+		t.Errorf("CentralMoment(0) = %v, CentralMoment(1) = %v", s.CentralMoment(0), s.CentralMoment(1))
+	}
+	s.CreateBins(3, 0, 10)
+	if got := s.CentralMoment(2); got != 4 {
+		t.Errorf("CentralMoment(2) after CreateBins = %v, expected 4", got)
+	}
+	expectPanic(t, "CentralMoment(3) after CreateBins", func() { s.CentralMoment(3) })
+}
+
+func TestPercentileTable(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := NewStats()
+	for i := 0; i < 1000; i++ {
+		s.AddSample(Sample(r.Float64()))
+	}
+	deciles := s.PercentileTable(0.1)
+	if len(deciles) != 11 {
+		t.Fatalf("len(PercentileTable(0.1)) = %d, expected 11", len(deciles))
+	}
+	for i, d := range deciles {
+		pct := float64(i) / 10
+		if exp := s.Percentile(pct); d != exp {
+			t.Errorf("decile %d = %v, expected Percentile(%v) = %v", i, d, pct, exp)
+		}
+	}
+	if n := len(s.PercentileTable(0.01)); n != 101 {
+		t.Errorf("len(PercentileTable(0.01)) = %d, expected 101", n)
+	}
+	expectPanic(t, "PercentileTable(0.3)", func() { s.PercentileTable(0.3) })
+	expectPanic(t, "PercentileTable(0)", func() { s.PercentileTable(0) })
+}
+
+func TestCreateBinsKeepSamples(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{0.5, 1.5, 2.5})
+	s.CreateBinsKeepSamples(5, 0, 3)
+	insertSamples(s, []Sample{1.5, 3.5})
+	for i, exp := range []int{0, 1, 2, 1, 1} {
+		if count, _, _ := s.Bin(i); count != exp {
+			t.Errorf("bin %d count = %d, expected %d", i, count, exp)
+		}
+	}
+	chkPct(t, s, 0, 0.5)
+	chkPct(t, s, .5, 1.5)
+	chkPct(t, s, 1, 3.5)
+	if s.Median() != 1.5 {
+		t.Errorf("Median() = %v, expected 1.5", s.Median())
+	}
+
+	s.RebinReset(3, 0, 2)
+	for i, exp := range []int{0, 3, 2} {
+		if count, _, _ := s.Bin(i); count != exp {
+			t.Errorf("after RebinReset bin %d count = %d, expected %d", i, count, exp)
+		}
+	}
+	chkPct(t, s, 1, 3.5)
+
+	s.CreateBins(3, 0, 2)
+	expectPanic(t, "Percentile after CreateBins", func() { s.Percentile(.5) })
+}
+
+func TestPercentileStderr(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	small, large := NewStats(), NewStats()
+	for i := 0; i < 10000; i++ {
+		val := Sample(r.NormFloat64())
+		if i < 100 {
+			small.AddSample(val)
+		}
+		large.AddSample(val)
+	}
+	se100, se10000 := small.PercentileStderr(.9, 200), large.PercentileStderr(.9, 200)
+	// the standard error shrinks as 1/sqrt(n), so by about a factor of 10
+	if se10000 >= se100/3 {
+		t.Errorf("standard error for 10000 samples %v not well below that for 100 samples %v", se10000, se100)
+	}
+	if se10000 <= 0 {
+		t.Errorf("standard error for 10000 samples = %v, expected positive", se10000)
+	}
+}
+
+func TestInterPercentileRange(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{0, 1, 10, 25, 100})
+	if got := s.InterPercentileRange(.25, .75); got != 24 || got != s.CentralSpread(.5) {
+		t.Errorf("InterPercentileRange(.25, .75) = %v, expected IQR 24", got)
+	}
+	if got := s.InterPercentileRange(0, 1); got != 100 {
+		t.Errorf("InterPercentileRange(0, 1) = %v, expected 100", got)
+	}
+	expectPanic(t, "InterPercentileRange(.75, .25)", func() { s.InterPercentileRange(.75, .25) })
+	expectPanic(t, "InterPercentileRange(-.1, .5)", func() { s.InterPercentileRange(-.1, .5) })
+	expectPanic(t, "InterPercentileRange(.5, 1.1)", func() { s.InterPercentileRange(.5, 1.1) })
+}
+
+func TestRobustZScore(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{1, 2, 3, 4, 5, 6, 7, 8, 9, 1000})
+	// median 5.5, absolute deviations 0.5, 0.5, 1.5, 1.5, ..., 4.5, 994.5
+	if s.MAD() != 2.5 {
+		t.Errorf("MAD() = %v, expected 2.5", s.MAD())
+	}
+	if got, exp := s.RobustZScore(1000), 0.6745*994.5/2.5; math.Abs(got-exp) > 1e-9 {
+		t.Errorf("RobustZScore(1000) = %v, expected %v", got, exp)
+	}
+	// the outlier inflates the standard deviation, hiding itself from the
+	// classic z-score but not from the robust one
+	classic := (1000 - s.Mean()) / s.Stddev()
+	if classic > 3.5 || s.RobustZScore(1000) < 100 {
+		t.Errorf("classic z-score %v, robust %v", classic, s.RobustZScore(1000))
+	}
+	if got := s.RobustZScore(5.5); got != 0 {
+		t.Errorf("RobustZScore(median) = %v, expected 0", got)
+	}
+
+	s = NewStats()
+	insertSamples(s, []Sample{1, 1, 1, 2})
+	if s.RobustZScore(1) != 0 || !math.IsInf(s.RobustZScore(2), 1) {
+		t.Errorf("zero MAD: RobustZScore(1) = %v, RobustZScore(2) = %v", s.RobustZScore(1), s.RobustZScore(2))
+	}
+}
+
+func TestProbabilityInRange(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := NewStats()
+	s.CreateBinsKeepSamples(22, 0, 100)
+	for i := 0; i < 10000; i++ {
+		s.AddSample(Sample(50 + 25*r.NormFloat64()))
+	}
+	for _, rng := range [][2]Sample{
+		{0, 200},
+		{12.3, 57.8},
+		{-20, 30},
+		{90, 1000},
+		{50, 50},
+	} {
+		low, high := rng[0], rng[1]
+		in := 0
+		for _, val := range s.samples {
+			if low <= val && val <= high {
+				in++
+			}
+		}
+		exp := float64(in) / float64(len(s.samples))
+		// ranges cutting into the edge bins are less accurate, as the
+		// samples there are far from evenly spread
+		if got := s.ProbabilityInRange(low, high); math.Abs(got-exp) > 0.02 {
+			t.Errorf("ProbabilityInRange(%v, %v) = %v, expected about %v", low, high, got, exp)
+		}
+	}
+	if got := s.ProbabilityInRange(-1e9, 1e9); math.Abs(got-1) > 1e-12 {
+		t.Errorf("ProbabilityInRange of everything = %v, expected 1", got)
+	}
+}
+
+func TestMedianBin(t *testing.T) {
+	s := NewStats()
+	s.CreateBins(7, 0, 50)
+	if s.MedianBin() != -1 {
+		t.Errorf("empty MedianBin() = %d, expected -1", s.MedianBin())
+	}
+	// bins: (-Inf,0] (0,10] (10,20] (20,30] (30,40] (40,50] (50,+Inf)
+	insertSamples(s, []Sample{-5, 5, 15, 25, 25, 26, 27, 28, 35, 100})
+	if s.MedianBin() != 3 {
+		t.Errorf("MedianBin() = %d, expected 3", s.MedianBin())
+	}
+}
+
+func TestDensestBin(t *testing.T) {
+	s := NewStats()
+	s.CreateBins(5, 0, 3)
+	if s.DensestBin() != -1 {
+		t.Errorf("empty DensestBin() = %d, expected -1", s.DensestBin())
+	}
+	// bins: (-Inf,0] (0,1] (1,2] (2,3] (3,+Inf)
+	insertSamples(s, []Sample{0.5, 1.2, 1.4, 1.6, 1.8, 1.9, 2.5})
+	for i := 0; i < 10; i++ {
+		// the top edge bin holds the most samples, spread over (3,100]
+		s.AddSample(Sample(10 + 10*i))
+	}
+	countMode, maxCount := 0, 0
+	for i := 0; i < s.NBins(); i++ {
+		if count, _, _ := s.Bin(i); count > maxCount {
+			countMode, maxCount = i, count
+		}
+	}
+	if countMode != 4 {
+		t.Fatalf("count mode = %d, expected 4", countMode)
+	}
+	if s.DensestBin() != 2 {
+		t.Errorf("DensestBin() = %d, expected 2", s.DensestBin())
+	}
+
+	// a zero width edge bin takes its neighbour's width
+	s = NewStats()
+	s.CreateBins(4, 0, 2)
+	insertSamples(s, []Sample{0, 0, 0, 0.5, 0.7, 1.5})
+	if s.DensestBin() != 0 {
+		t.Errorf("DensestBin() = %d, expected 0", s.DensestBin())
+	}
+}
+
+func TestUnbiasedVariance(t *testing.T) {
+	samples := []Sample{2, 4, 4, 4, 5, 5, 7, 9}
+	pop, unbiased := NewStats(), NewStats(WithUnbiasedVariance())
+	insertSamples(pop, samples)
+	insertSamples(unbiased, samples)
+	if pop.Variance() != 4 || pop.Stddev() != 2 {
+		t.Errorf("population Variance() = %v, Stddev() = %v", pop.Variance(), pop.Stddev())
+	}
+	if got, exp := unbiased.Variance(), 32.0/7; math.Abs(got-exp) > 1e-12 {
+		t.Errorf("unbiased Variance() = %v, expected %v", got, exp)
+	}
+	if got, exp := unbiased.Stddev(), math.Sqrt(32.0/7); math.Abs(got-exp) > 1e-12 {
+		t.Errorf("unbiased Stddev() = %v, expected %v", got, exp)
+	}
+	if unbiased.CentralMoment(2) != 4 {
+		t.Errorf("unbiased CentralMoment(2) = %v, expected 4", unbiased.CentralMoment(2))
+	}
+	f := unbiased.FilterStats(func(val Sample) bool { return val < 9 })
+	if got, exp := f.Variance(), 16.0/7; math.Abs(got-exp) > 1e-12 {
+		t.Errorf("filtered Variance() = %v, expected %v", got, exp)
+	}
+}
+
+func TestAddComplex(t *testing.T) {
+	s := NewStats()
+	s.AddComplex(3 + 4i)
+	s.AddComplex(-6i)
+	s.AddComplex(-1)
+	if s.Count() != 3 || s.Mean() != 4 || s.Median() != 5 {
+		t.Errorf("count %d, mean %v, median %v, expected 3, 4, 5", s.Count(), s.Mean(), s.Median())
+	}
+}
+
+func TestNonZeroStats(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{0, 4, 0, 0, 2, 0, 6})
+	nz := s.NonZeroStats()
+	if nz.Count() != 3 || nz.Mean() != 4 || nz.Min() != 2 {
+		t.Errorf("NonZeroStats(): count %d, mean %v, min %v", nz.Count(), nz.Mean(), nz.Min())
+	}
+	if s.ZeroCount() != 4 {
+		t.Errorf("ZeroCount() = %d, expected 4", s.ZeroCount())
+	}
+	s.CreateBins(3, 0, 1)
+	expectPanic(t, "ZeroCount after CreateBins", func() { s.ZeroCount() })
+}
+
+func TestPercentileSample(t *testing.T) {
+	samples := []Sample{30, 10, 50, 20, 40}
+	s := NewStats(TrackIndices())
+	if val, i := s.PercentileSample(.5); val != 0 || i != -1 {
+		t.Errorf("empty PercentileSample(.5) = (%v, %d), expected (0, -1)", val, i)
+	}
+	insertSamples(s, samples)
+	for _, pct := range []float64{0, .25, .5, .75, 1} {
+		val, i := s.PercentileSample(pct)
+		if val != s.Percentile(pct) {
+			t.Errorf("PercentileSample(%v) value = %v, expected %v", pct, val, s.Percentile(pct))
+		}
+		if samples[i] != val {
+			t.Errorf("PercentileSample(%v) index %d holds %v, not %v", pct, i, samples[i], val)
+		}
+	}
+
+	// indices survive selection, binning with retained samples and cloning
+	s = NewStats(TrackIndices())
+	insertSamples(s, samples)
+	s.Percentile(.3)
+	s.CreateBinsKeepSamples(3, 0, 100)
+	s.AddSample(5)
+	samples = append(samples, 5)
+	c := s.Clone()
+	for _, pct := range []float64{0, .2, .4, .6, .8, 1} {
+		val, i := c.PercentileSample(pct)
+		if samples[i] != val {
+			t.Errorf("PercentileSample(%v) index %d holds %v, not %v", pct, i, samples[i], val)
+		}
+	}
+
+	expectPanic(t, "PercentileSample without tracking", func() { NewStats().PercentileSample(.5) })
+}
+
+func TestConsumeChannel(t *testing.T) {
+	s := NewStats()
+	ch := make(chan Sample)
+	result := make(chan int)
+	go func() { result <- s.ConsumeChannel(ch, nil) }()
+	for i := 1; i <= 100; i++ {
+		ch <- Sample(i)
+	}
+	close(ch)
+	if n := <-result; n != 100 {
+		t.Errorf("ConsumeChannel() = %d, expected 100", n)
+	}
+	if s.Count() != 100 || s.Mean() != 50.5 {
+		t.Errorf("Count() = %d, Mean() = %v, expected 100, 50.5", s.Count(), s.Mean())
+	}
+
+	// done stops consumption of an open channel
+	s = NewStats()
+	ch = make(chan Sample, 3)
+	ch <- 1
+	ch <- 2
+	done := make(chan struct{})
+	go func() { result <- s.ConsumeChannel(ch, done) }()
+	for len(ch) > 0 {
+		runtime.Gosched()
+	}
+	close(done)
+	if n := <-result; n != 2 {
+		t.Errorf("ConsumeChannel() = %d after done, expected 2", n)
+	}
+}
+
+func TestPercentRSD(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{2, 4, 4, 4, 5, 5, 7, 9})
+	if got := s.PercentRSD(); math.Abs(got-40) > 1e-9 {
+		t.Errorf("PercentRSD() = %v, expected 40", got)
+	}
+	// replicate assay results with a sample standard deviation of 0.1
+	s = NewStats(WithUnbiasedVariance())
+	insertSamples(s, []Sample{9.9, 10.0, 10.1})
+	if got := s.PercentRSD(); math.Abs(got-1) > 1e-9 {
+		t.Errorf("unbiased PercentRSD() = %v, expected 1", got)
+	}
+	s = NewStats()
+	insertSamples(s, []Sample{-1, 1})
+	if got := s.PercentRSD(); !math.IsInf(got, 1) {
+		t.Errorf("PercentRSD() with zero mean = %v, expected +Inf", got)
+	}
+}
+
+func TestIsBinned(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{1, 2, 3})
+	if s.IsBinned() {
+		t.Errorf("IsBinned() = true before CreateBins")
+	}
+	s.CreateBins(4, 0, 10)
+	if !s.IsBinned() {
+		t.Errorf("IsBinned() = false after CreateBins")
+	}
+}
+
+func TestMedianStable(t *testing.T) {
+	samples := []Sample{5, 1, 4, 2, 3, 6}
+	s := NewStats()
+	insertSamples(s, samples)
+	if got := s.MedianStable(); got != 3.5 {
+		t.Errorf("MedianStable() = %v, expected 3.5", got)
+	}
+	for i, val := range s.samples {
+		if val != samples[i] {
+			t.Fatalf("samples reordered to %v, expected %v", s.samples, samples)
+		}
+	}
+	if got := s.Median(); got != 3.5 {
+		t.Errorf("Median() = %v, expected 3.5", got)
+	}
+}
+
+func TestNearestToMean(t *testing.T) {
+	for i, test := range []struct {
+		samples []Sample
+		nearest Sample
+	}{
+		// 2 and 9 are both 3.5 from the mean of 5.5
+		{[]Sample{1, 2, 9, 10}, 2},
+		{[]Sample{10, 9, 2, 1}, 2},
+		{[]Sample{1, 2, 3, 10}, 3},
+		{[]Sample{7}, 7},
+		{[]Sample{}, 0},
+	} {
+		s := NewStats()
+		insertSamples(s, test.samples)
+		if got := s.NearestToMean(); got != test.nearest {
+			t.Errorf("[%d] NearestToMean() = %v, expected %v", i, got, test.nearest)
+		}
+	}
+	s := NewStats()
+	s.CreateBins(3, 0, 1)
+	expectPanic(t, "NearestToMean after CreateBins", func() { s.NearestToMean() })
+}
+
+func TestNormalize(t *testing.T) {
+	s := NewStats()
+	if got := s.Normalize(3); got != 0.5 {
+		t.Errorf("empty Normalize(3) = %v, expected 0.5", got)
+	}
+	insertSamples(s, []Sample{10, 30, 20})
+	for _, test := range []struct {
+		val  Sample
+		norm float64
+	}{
+		{10, 0}, {20, 0.5}, {30, 1}, {25, 0.75}, {0, 0}, {40, 1},
+	} {
+		if got := s.Normalize(test.val); got != test.norm {
+			t.Errorf("Normalize(%v) = %v, expected %v", test.val, got, test.norm)
+		}
+	}
+	expectPanic(t, "NormalizeAll without TrackIndices", func() { s.NormalizeAll() })
+
+	s = NewStats(TrackIndices())
+	insertSamples(s, []Sample{30, 10, 20})
+	s.Median()
+	exp := []float64{1, 0, 0.5}
+	for i, got := range s.NormalizeAll() {
+		if got != exp[i] {
+			t.Errorf("tracked NormalizeAll()[%d] = %v, expected %v", i, got, exp[i])
+		}
+	}
+
+	s = NewStats()
+	insertSamples(s, []Sample{7, 7})
+	if got := s.Normalize(7); got != 0.5 {
+		t.Errorf("constant Normalize(7) = %v, expected 0.5", got)
+	}
+}
+
+func TestZScores(t *testing.T) {
+	samples := []Sample{9, 2, 5, 4, 12, 7, 8, 11, 9, 3}
+	s := NewStats(TrackIndices())
+	insertSamples(s, samples)
+	z := s.ZScores()
+	if len(z) != len(samples) {
+		t.Fatalf("len(ZScores()) = %d, expected %d", len(z), len(samples))
+	}
+	zs := NewStats()
+	for i, v := range z {
+		if exp := (float64(samples[i]) - s.Mean()) / s.Stddev(); v != exp {
+			t.Errorf("ZScores()[%d] = %v, expected %v", i, v, exp)
+		}
+		zs.AddSample(Sample(v))
+	}
+	if math.Abs(zs.Mean()) > 1e-12 || math.Abs(zs.Stddev()-1) > 1e-12 {
+		t.Errorf("z-scores have mean %v and stddev %v, expected 0 and 1", zs.Mean(), zs.Stddev())
+	}
+
+	// insertion order is recovered after sorting with TrackIndices
+	s = NewStats(TrackIndices())
+	insertSamples(s, []Sample{3, 1, 2})
+	s.Percentile(.5)
+	s.Median()
+	sd := math.Sqrt(2.0 / 3)
+	for i, exp := range []float64{1 / sd, -1 / sd, 0} {
+		if got := s.ZScores()[i]; math.Abs(got-exp) > 1e-12 {
+			t.Errorf("tracked ZScores()[%d] = %v, expected %v", i, got, exp)
+		}
+	}
+
+	s = NewStats()
+	insertSamples(s, samples)
+	expectPanic(t, "ZScores without TrackIndices", func() { s.ZScores() })
+
+	s = NewStats(TrackIndices())
+	insertSamples(s, []Sample{4, 4, 4})
+	for i, v := range s.ZScores() {
+		if v != 0 {
+			t.Errorf("constant ZScores()[%d] = %v, expected 0", i, v)
+		}
+	}
+	s.CreateBins(3, 0, 1)
+	expectPanic(t, "ZScores after CreateBins", func() { s.ZScores() })
+}
+
+func TestPercentileCI(t *testing.T) {
+	small, large := NewStats(), NewStats()
+	for i := 1; i <= 100; i++ {
+		small.AddSample(Sample(i))
+	}
+	for i := 1; i <= 1000; i++ {
+		large.AddSample(Sample(i) / 10)
+	}
+	for _, pct := range []float64{.5, .9} {
+		prevWidth := Sample(0)
+		for _, conf := range []float64{.5, .9, .99} {
+			low, high := small.PercentileCI(pct, conf)
+			if p := small.Percentile(pct); low > p || high < p {
+				t.Errorf("PercentileCI(%v, %v) = [%v, %v] excludes %v", pct, conf, low, high, p)
+			}
+			if high-low <= prevWidth {
+				t.Errorf("PercentileCI(%v, %v) width %v, expected more than %v", pct, conf, high-low, prevWidth)
+			}
+			prevWidth = high - low
+			llow, lhigh := large.PercentileCI(pct, conf)
+			if lhigh-llow >= high-low {
+				t.Errorf("PercentileCI(%v, %v) width %v with 1000 samples, expected less than %v with 100", pct, conf, lhigh-llow, high-low)
+			}
+		}
+	}
+	// for the median of 100 samples P(B <= 39) = P(B >= 61) = 0.0176, so the
+	// 95% interval is [x(40), x(61)] with coverage 0.965
+	if low, high := small.PercentileCI(.5, .95); low != 40 || high != 61 {
+		t.Errorf("PercentileCI(.5, .95) = [%v, %v], expected [40, 61]", low, high)
+	}
+	// the interval covers the true percentile at least as often as promised
+	rng := rand.New(rand.NewSource(1))
+	covered := 0
+	const trials = 2000
+	for i := 0; i < trials; i++ {
+		s := NewStats()
+		for j := 0; j < 50; j++ {
+			s.AddSample(Sample(rng.Float64()))
+		}
+		if low, high := s.PercentileCI(.9, .95); low <= .9 && high >= .9 {
+			covered++
+		}
+	}
+	if frac := float64(covered) / trials; frac < .94 {
+		t.Errorf("PercentileCI(.9, .95) covered the true percentile %v of the time", frac)
+	}
+	expectPanic(t, "PercentileCI with confidence 1", func() { small.PercentileCI(.5, 1) })
+}
+
+func TestResample(t *testing.T) {
+	s := NewStats()
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		s.AddSample(Sample(rng.ExpFloat64()))
+	}
+	r := NewStats()
+	insertSamples(r, s.Resample(20000, rng))
+	if r.Count() != 20000 {
+		t.Fatalf("Resample(20000) returned %d samples", r.Count())
+	}
+	if math.Abs(r.Mean()-s.Mean()) > 0.05*s.Mean() {
+		t.Errorf("resampled mean %v, expected about %v", r.Mean(), s.Mean())
+	}
+	for _, pct := range []float64{.1, .5, .9} {
+		if got, exp := r.Percentile(pct), s.Percentile(pct); math.Abs(float64(got-exp)) > 0.05*float64(s.Percentile(.9)) {
+			t.Errorf("resampled Percentile(%v) = %v, expected about %v", pct, got, exp)
+		}
+	}
+	if r.Min() < s.Min() || r.Max() > s.Max() {
+		t.Errorf("resampled range [%v, %v] outside [%v, %v]", r.Min(), r.Max(), s.Min(), s.Max())
+	}
+	if got := NewStats().Resample(3, nil); got != nil {
+		t.Errorf("empty Resample(3) = %v, expected nil", got)
+	}
+}
+
+func TestQQNormal(t *testing.T) {
+	s := NewStats()
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		s.AddSample(Sample(10 + 2*rng.NormFloat64()))
+	}
+	theoretical, sample := s.QQNormal()
+	if len(theoretical) != 500 || len(sample) != 500 {
+		t.Fatalf("QQNormal() returned %d and %d points, expected 500", len(theoretical), len(sample))
+	}
+	if math.Abs(theoretical[250]-(-theoretical[249])) > 1e-12 {
+		t.Errorf("theoretical quantiles not symmetric: %v, %v", theoretical[249], theoretical[250])
+	}
+	p := NewPairedStats()
+	for i := range sample {
+		if i > 0 && sample[i] < sample[i-1] {
+			t.Fatalf("sample quantiles not sorted at %d", i)
+		}
+		p.AddPair(Sample(theoretical[i]), Sample(sample[i]))
+	}
+	if r := p.Correlation(); r < 0.99 {
+		t.Errorf("QQ correlation for normal data = %v, expected over 0.99", r)
+	}
+
+	s = NewStats()
+	for i := 0; i < 500; i++ {
+		s.AddSample(Sample(rng.ExpFloat64()))
+	}
+	theoretical, sample = s.QQNormal()
+	p = NewPairedStats()
+	for i := range sample {
+		p.AddPair(Sample(theoretical[i]), Sample(sample[i]))
+	}
+	if r := p.Correlation(); r > 0.95 {
+		t.Errorf("QQ correlation for exponential data = %v, expected under 0.95", r)
+	}
+	s.CreateBins(3, 0, 1)
+	expectPanic(t, "QQNormal after CreateBins", func() { s.QQNormal() })
+}
+
+func TestMSE(t *testing.T) {
+	samples := []Sample{1.5, 3, -2, 4.25, 0, 7}
+	s := NewStats()
+	insertSamples(s, samples)
+	s.CreateBins(3, 0, 1)
+	for _, target := range []Sample{0, 2, -3.5, 10} {
+		var sq float64
+		for _, x := range samples {
+			d := float64(x - target)
+			sq += d * d
+		}
+		exp := sq / float64(len(samples))
+		if got := s.MSE(target); math.Abs(got-exp) > 1e-12 {
+			t.Errorf("MSE(%v) = %v, expected %v", target, got, exp)
+		}
+		if got := s.RMSE(target); math.Abs(got-math.Sqrt(exp)) > 1e-12 {
+			t.Errorf("RMSE(%v) = %v, expected %v", target, got, math.Sqrt(exp))
+		}
+	}
+	s = NewStats()
+	insertSamples(s, []Sample{0.1, 0.1, 0.1})
+	if got := s.RMSE(0.1); got < 0 || got > 1e-8 {
+		t.Errorf("RMSE(0.1) of identical samples = %v, expected 0", got)
+	}
+}
+
+func TestMannKendall(t *testing.T) {
+	s := NewStats(TrackIndices())
+	for i := 0; i < 20; i++ {
+		s.AddSample(Sample(i))
+	}
+	if tau, trend := s.MannKendall(); tau != 1 || trend != 1 {
+		t.Errorf("increasing MannKendall() = (%v, %d), expected (1, 1)", tau, trend)
+	}
+	s = NewStats(TrackIndices())
+	for i := 0; i < 20; i++ {
+		s.AddSample(Sample(-i))
+	}
+	if tau, trend := s.MannKendall(); tau != -1 || trend != -1 {
+		t.Errorf("decreasing MannKendall() = (%v, %d), expected (-1, -1)", tau, trend)
+	}
+
+	s = NewStats()
+	s.AddSample(1)
+	expectPanic(t, "MannKendall without TrackIndices", func() { s.MannKendall() })
+
+	s = NewStats(TrackIndices())
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		s.AddSample(Sample(rng.Float64()))
+	}
+	if tau, trend := s.MannKendall(); math.Abs(tau) > 0.1 || trend != 0 {
+		t.Errorf("random MannKendall() = (%v, %d), expected about (0, 0)", tau, trend)
+	}
+
+	// a weak trend in noise, recovered after sorting with TrackIndices
+	s = NewStats(TrackIndices())
+	for i := 0; i < 200; i++ {
+		s.AddSample(Sample(rng.Float64() + float64(i)/200))
+	}
+	s.Median()
+	if tau, trend := s.MannKendall(); tau < 0.2 || tau > 0.6 || trend != 1 {
+		t.Errorf("trending MannKendall() = (%v, %d), expected tau about 0.4 and trend 1", tau, trend)
+	}
+}
+
+func TestAutocorrelation(t *testing.T) {
+	// 10 periods of a sine wave of period 20; lags of whole half periods
+	// overlap in whole periods of sin², so the expected values are exact
+	s := NewStats(TrackIndices())
+	for i := 0; i < 200; i++ {
+		s.AddSample(Sample(math.Sin(2 * math.Pi * float64(i) / 20)))
+	}
+	for _, test := range []struct {
+		lag int
+		exp float64
+	}{
+		{0, 1},
+		{10, -0.95},
+		{20, 0.9},
+	} {
+		if got := s.Autocorrelation(test.lag); math.Abs(got-test.exp) > 1e-9 {
+			t.Errorf("Autocorrelation(%d) = %v, expected %v", test.lag, got, test.exp)
+		}
+	}
+	expectPanic(t, "Autocorrelation(200)", func() { s.Autocorrelation(200) })
+	expectPanic(t, "Autocorrelation(-1)", func() { s.Autocorrelation(-1) })
+
+	s = NewStats()
+	insertSamples(s, []Sample{1, 2, 3})
+	expectPanic(t, "Autocorrelation without TrackIndices", func() { s.Autocorrelation(1) })
+}
+
+func TestCompact(t *testing.T) {
+	s := NewStats(TrackIndices())
+	for i := 0; i < 1000; i++ {
+		s.AddSample(Sample(i))
+	}
+	if cap(s.samples) == len(s.samples) {
+		t.Fatalf("no spare capacity to release")
+	}
+	s.Compact()
+	if cap(s.samples) != 1000 || cap(s.indices) != 1000 {
+		t.Errorf("cap(samples), cap(indices) = %d, %d after Compact(), expected 1000", cap(s.samples), cap(s.indices))
+	}
+	for i, val := range s.samples {
+		if val != Sample(i) || s.indices[i] != i {
+			t.Fatalf("samples changed by Compact(): [%d] = %v, index %d", i, val, s.indices[i])
+		}
+	}
+	if s.Count() != 1000 || s.Median() != 499.5 {
+		t.Errorf("Count(), Median() = %d, %v after Compact(), expected 1000, 499.5", s.Count(), s.Median())
+	}
+}
+
+func TestBinFractions(t *testing.T) {
+	s := NewStats()
+	s.CreateBins(5, 0, 3)
+	insertSamples(s, []Sample{-1, 0.5, 1.5, 1.7, 2.2, 2.9, 2.95, 10})
+	f := s.BinFractions()
+	if len(f) != 5 {
+		t.Fatalf("len(BinFractions()) = %d, expected 5", len(f))
+	}
+	var sum float64
+	for i, frac := range f {
+		if exp := float64(s.binCounts[i]) / 8; frac != exp {
+			t.Errorf("BinFractions()[%d] = %v, expected %v", i, frac, exp)
+		}
+		sum += frac
+	}
+	if math.Abs(sum-1) > 1e-12 {
+		t.Errorf("BinFractions() sum to %v, expected 1", sum)
+	}
+	expectPanic(t, "BinFractions before CreateBins", func() { NewStats().BinFractions() })
+}
+
+func TestMeanExceeds(t *testing.T) {
+	// mean 5, sample stddev sqrt(32/7)
+	s := NewStats()
+	insertSamples(s, []Sample{2, 4, 4, 4, 5, 5, 7, 9})
+	se := math.Sqrt(32.0 / 7 / 8)
+	for _, threshold := range []Sample{5, 1, 10} {
+		exp := (5 - float64(threshold)) / se
+		if got := s.MeanExceeds(threshold); math.Abs(got-exp) > 1e-12 {
+			t.Errorf("MeanExceeds(%v) = %v, expected %v", threshold, got, exp)
+		}
+	}
+	if z := s.MeanExceeds(1); z < 3 {
+		t.Errorf("MeanExceeds(1) = %v, expected clearly above", z)
+	}
+	if z := s.MeanExceeds(10); z > -3 {
+		t.Errorf("MeanExceeds(10) = %v, expected clearly below", z)
+	}
+	s.CreateBins(3, 0, 1)
+	if got := s.MeanExceeds(5); got != 0 {
+		t.Errorf("MeanExceeds(5) after CreateBins = %v, expected 0", got)
+	}
+
+	s = NewStats()
+	if got := s.MeanExceeds(0); !math.IsNaN(got) {
+		t.Errorf("empty MeanExceeds(0) = %v, expected NaN", got)
+	}
+	s.AddSample(3)
+	if got := s.MeanExceeds(0); !math.IsNaN(got) {
+		t.Errorf("single MeanExceeds(0) = %v, expected NaN", got)
+	}
+	s.AddSample(3)
+	s.AddSample(3)
+	for _, test := range []struct {
+		threshold Sample
+		check     func(float64) bool
+		exp       string
+	}{
+		{0, func(z float64) bool { return math.IsInf(z, 1) }, "+Inf"},
+		{5, func(z float64) bool { return math.IsInf(z, -1) }, "-Inf"},
+		{3, math.IsNaN, "NaN"},
+	} {
+		if got := s.MeanExceeds(test.threshold); !test.check(got) {
+			t.Errorf("constant MeanExceeds(%v) = %v, expected %s", test.threshold, got, test.exp)
+		}
+	}
+}
+
+func TestRecommendBinCount(t *testing.T) {
+	for _, test := range []struct {
+		low, high   Sample
+		targetError float64
+		nbins       int
+	}{
+		{0, 10, 1, 12},
+		{0, 10, 0.3, 36},
+		{0, 10, 100, 3},
+		{-5, 5, 0.1, 102},
+	} {
+		if got := RecommendBinCount(test.low, test.high, test.targetError); got != test.nbins {
+			t.Errorf("RecommendBinCount(%v, %v, %v) = %d, expected %d", test.low, test.high, test.targetError, got, test.nbins)
+		}
+	}
+	prev := 0
+	for _, targetError := range []float64{5, 1, 0.5, 0.1, 0.01} {
+		n := RecommendBinCount(0, 100, targetError)
+		if n <= prev {
+			t.Errorf("RecommendBinCount(0, 100, %v) = %d, expected more than %d", targetError, n, prev)
+		}
+		prev = n
+	}
+
+	// the bound holds for the interior percentiles of evenly spread data
+	s := NewStats()
+	s.CreateBins(RecommendBinCount(0, 10, 0.5), 0, 10)
+	for i := 0; i < 1000; i++ {
+		s.AddSample(Sample(i) / 100)
+	}
+	for _, pct := range []float64{.1, .25, .5, .9} {
+		if got := s.BinnedPercentile(pct); math.Abs(float64(got)-10*pct) > 0.5 {
+			t.Errorf("BinnedPercentile(%v) = %v, expected within 0.5 of %v", pct, got, 10*pct)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{40, 20, 10, 30, 20})
+	for _, test := range []struct {
+		val  Sample
+		rank int
+	}{
+		{25, 3}, {20, 1}, {5, 0}, {10, 0}, {40, 4}, {45, 5},
+	} {
+		if got := s.Rank(test.val); got != test.rank {
+			t.Errorf("Rank(%v) = %d, expected %d", test.val, got, test.rank)
+		}
+	}
+	s = NewStats()
+	insertSamples(s, []Sample{10, 20, 30, 40})
+	if got := s.Rank(25); got != 2 {
+		t.Errorf("Rank(25) = %d, expected 2", got)
+	}
+	s.CreateBins(3, 0, 1)
+	expectPanic(t, "Rank after CreateBins", func() { s.Rank(25) })
+}
+
+func TestLogStats(t *testing.T) {
+	s := NewStats(TrackLogReciprocal())
+	insertSamples(s, []Sample{1, 2, 4, 8, 16})
+	l := s.LogStats()
+	if l.Count() != 5 {
+		t.Errorf("LogStats().Count() = %d, expected 5", l.Count())
+	}
+	if got, exp := math.Exp(l.Mean()), s.GeometricMean(); math.Abs(got-exp) > 1e-12 {
+		t.Errorf("exp(LogStats().Mean()) = %v, expected %v", got, exp)
+	}
+	if got := math.Exp(l.Mean()); math.Abs(got-4) > 1e-12 {
+		t.Errorf("exp(LogStats().Mean()) = %v, expected 4", got)
+	}
+	// logs are ln(2)·{0, 1, 2, 3, 4}, population stddev ln(2)·sqrt(2)
+	if got, exp := math.Exp(l.Stddev()), math.Pow(2, math.Sqrt2); math.Abs(got-exp) > 1e-12 {
+		t.Errorf("geometric stddev = %v, expected %v", got, exp)
+	}
+	s.AddSample(0)
+	expectPanic(t, "LogStats with a zero sample", func() { s.LogStats() })
+	s = NewStats()
+	s.CreateBins(3, 0, 1)
+	expectPanic(t, "LogStats after CreateBins", func() { s.LogStats() })
+}
+
+func TestEqualizationMap(t *testing.T) {
+	// skewed samples, bunched towards 0
+	s := NewStats()
+	s.CreateBins(12, 0, 10)
+	for i := 0; i < 1000; i++ {
+		u := (Sample(i) + 0.5) / 1000
+		s.AddSample(10 * u * u)
+	}
+	eq := s.EqualizationMap()
+	if got := eq(s.Min()); got != 0 {
+		t.Errorf("eq(Min()) = %v, expected 0", got)
+	}
+	if got := eq(s.Max()); got != 1 {
+		t.Errorf("eq(Max()) = %v, expected 1", got)
+	}
+	if eq(-1) != 0 || eq(11) != 1 {
+		t.Errorf("eq(-1), eq(11) = %v, %v, expected 0, 1", eq(-1), eq(11))
+	}
+	prev := 0.0
+	for x := Sample(0); x <= 10; x += 0.01 {
+		got := eq(x)
+		if got < prev {
+			t.Fatalf("eq(%v) = %v, less than %v before it", x, got, prev)
+		}
+		prev = got
+	}
+	// the equalized samples are spread evenly: 10u² maps to about u
+	if got := eq(2.5); math.Abs(got-0.5) > 0.05 {
+		t.Errorf("eq(2.5) = %v, expected about 0.5", got)
+	}
+	expectPanic(t, "EqualizationMap before CreateBins", func() { NewStats().EqualizationMap() })
+}
+
+func TestNaturalOrder(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{1, 2, 2, 5})
+	if !s.sorted {
+		t.Errorf("ascending samples not known to be sorted")
+	}
+	if got := s.Percentile(.5); got != 2 {
+		t.Errorf("Percentile(.5) = %v, expected 2", got)
+	}
+	s.AddSample(3)
+	if s.sorted {
+		t.Errorf("sorted after an out-of-order sample")
+	}
+	if got := s.Percentile(.75); got != 3 {
+		t.Errorf("Percentile(.75) = %v, expected 3", got)
+	}
+
+	// binning empties the samples, or keeps them with their order
+	s = NewStats()
+	insertSamples(s, []Sample{1, 3, 2})
+	s.CreateBinsKeepSamples(3, 0, 10)
+	if s.sorted {
+		t.Errorf("sorted after keeping out-of-order samples")
+	}
+	s.CreateBins(3, 0, 10)
+	if !s.sorted {
+		t.Errorf("no samples not known to be sorted")
+	}
+	s = NewStats()
+	insertSamples(s, []Sample{1, 2, 3})
+	s.CreateBinsKeepSamples(3, 0, 10)
+	s.AddSample(4)
+	if !s.sorted || s.Median() != 2.5 {
+		t.Errorf("sorted, Median() = %v, %v after keeping ascending samples, expected true, 2.5", s.sorted, s.Median())
+	}
+}
+
+func TestExpectedExceedances(t *testing.T) {
+	s := NewStats()
+	for i := 1; i <= 200; i++ {
+		s.AddSample(Sample(i))
+	}
+	if got := s.CountAbove(180); got != 20 {
+		t.Errorf("CountAbove(180) = %d, expected 20", got)
+	}
+	if got := s.CountAbove(200); got != 0 {
+		t.Errorf("CountAbove(200) = %d, expected 0", got)
+	}
+	// 10% of the samples exceed 180
+	if got := s.ExpectedExceedances(180, 1000); math.Abs(got-100) > 1e-9 {
+		t.Errorf("ExpectedExceedances(180, 1000) = %v, expected 100", got)
+	}
+	if got := s.ExpectedExceedances(0, 50); got != 50 {
+		t.Errorf("ExpectedExceedances(0, 50) = %v, expected 50", got)
+	}
+	if got := NewStats().ExpectedExceedances(0, 50); !math.IsNaN(got) {
+		t.Errorf("empty ExpectedExceedances(0, 50) = %v, expected NaN", got)
+	}
+	s.CreateBins(3, 0, 1)
+	expectPanic(t, "CountAbove after CreateBins", func() { s.CountAbove(0) })
+}
+
+func TestCollapseBins(t *testing.T) {
+	s := NewStats()
+	s.CreateBinsTrackSum(10, 0, 8)
+	insertSamples(s, []Sample{-1, 0.5, 1.5, 1.6, 2.5, 3.5, 3.7, 4.5, 5.5, 6.5, 7.5, 7.7, 9})
+	c := s.CollapseBins(2)
+	if c.NBins() != 5 {
+		t.Fatalf("NBins() = %d, expected 5", c.NBins())
+	}
+	expBins := []Sample{1, 3, 5, 7, math.MaxFloat64}
+	expCounts := []int{2, 3, 3, 2, 3}
+	for i := range expBins {
+		count, _, high := c.Bin(i)
+		if high != expBins[i] || count != expCounts[i] {
+			t.Errorf("Bin(%d) = %d, %v, expected %d, %v", i, count, high, expCounts[i], expBins[i])
+		}
+	}
+	if got := c.BinMean(1); math.Abs(got-5.6/3) > 1e-12 {
+		t.Errorf("BinMean(1) = %v, expected %v", got, 5.6/3)
+	}
+	if s.NBins() != 10 || c.Count() != s.Count() {
+		t.Errorf("original has %d bins, collapsed count %d, expected 10, %d", s.NBins(), c.Count(), s.Count())
+	}
+	// 10 bins by 3 leaves a remainder bin
+	c = s.CollapseBins(3)
+	expBins = []Sample{2, 5, 8, math.MaxFloat64}
+	expCounts = []int{4, 4, 4, 1}
+	for i := range expBins {
+		count, _, high := c.Bin(i)
+		if high != expBins[i] || count != expCounts[i] {
+			t.Errorf("Bin(%d) = %d, %v, expected %d, %v", i, count, high, expCounts[i], expBins[i])
+		}
+	}
+	c.AddSample(0.2)
+	if count, _, _ := c.Bin(0); count != 5 {
+		t.Errorf("collapsed Bin(0) count %d after AddSample, expected 5", count)
+	}
+	expectPanic(t, "CollapseBins(5)", func() { s.CollapseBins(5) })
+}
+
+func TestFrequencyOf(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{2, 1, 2, 3, 2})
+	for _, test := range []struct {
+		val  Sample
+		freq float64
+	}{
+		{2, 0.6}, {1, 0.2}, {3, 0.2}, {2.5, 0}, {0, 0}, {4, 0},
+	} {
+		if got := s.FrequencyOf(test.val); got != test.freq {
+			t.Errorf("FrequencyOf(%v) = %v, expected %v", test.val, got, test.freq)
+		}
+	}
+	if got := NewStats().FrequencyOf(1); !math.IsNaN(got) {
+		t.Errorf("empty FrequencyOf(1) = %v, expected NaN", got)
+	}
+	s.CreateBins(3, 0, 1)
+	expectPanic(t, "FrequencyOf after CreateBins", func() { s.FrequencyOf(2) })
+}
+
+func TestEdgeExtrapolation(t *testing.T) {
+	s := NewStats()
+	s.CreateBinsKeepSamples(12, 0, 10)
+	for i := 0; i < 100; i++ {
+		s.AddSample((Sample(i) + 0.5) / 10)
+	}
+	// a long tail beyond the binned range
+	insertSamples(s, []Sample{10, 20, 30, 40, 50})
+	exact := s.Percentile(.99)
+	clamped := s.BinnedPercentile(.99)
+	if clamped != 10 {
+		t.Errorf("clamped BinnedPercentile(.99) = %v, expected 10", clamped)
+	}
+	s.SetEdgeExtrapolation(true)
+	got := s.BinnedPercentile(.99)
+	if math.Abs(float64(got-exact)) >= math.Abs(float64(clamped-exact)) {
+		t.Errorf("extrapolated BinnedPercentile(.99) = %v, no closer to %v than %v", got, exact, clamped)
+	}
+	// 2.95 of the 4 samples above 10, spread over [10, 50]
+	if math.Abs(float64(got)-39.5) > 1e-9 {
+		t.Errorf("extrapolated BinnedPercentile(.99) = %v, expected 39.5", got)
+	}
+	if got := s.BinnedPercentile(1); got != 50 {
+		t.Errorf("extrapolated BinnedPercentile(1) = %v, expected the maximum 50", got)
+	}
+	if got := s.BinnedPercentile(.5); math.Abs(float64(got)-5.25) > 1e-9 {
+		t.Errorf("extrapolated BinnedPercentile(.5) = %v, expected 5.25 as without", got)
+	}
+}
+
+func TestTrimmedRange(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{100, 2, -100, 3, 1})
+	for _, test := range []struct {
+		drop      int
+		low, high Sample
+	}{
+		{0, -100, 100}, {1, 1, 3}, {2, 2, 2},
+	} {
+		if low, high := s.TrimmedRange(test.drop); low != test.low || high != test.high {
+			t.Errorf("TrimmedRange(%d) = (%v, %v), expected (%v, %v)", test.drop, low, high, test.low, test.high)
+		}
+	}
+	expectPanic(t, "TrimmedRange(3) of 5 samples", func() { s.TrimmedRange(3) })
+	s.CreateBins(3, 0, 1)
+	expectPanic(t, "TrimmedRange after CreateBins", func() { s.TrimmedRange(0) })
+}
+
+func TestSumTopK(t *testing.T) {
+	s := NewStats()
+	insertSamples(s, []Sample{3, 5, 1, 4, 2})
+	for _, test := range []struct {
+		k           int
+		top, bottom Sample
+	}{
+		{0, 0, 0}, {1, 5, 1}, {2, 9, 3}, {5, 15, 15},
+	} {
+		if got := s.SumTopK(test.k); got != test.top {
+			t.Errorf("SumTopK(%d) = %v, expected %v", test.k, got, test.top)
+		}
+		if got := s.SumBottomK(test.k); got != test.bottom {
+			t.Errorf("SumBottomK(%d) = %v, expected %v", test.k, got, test.bottom)
+		}
+	}
+	expectPanic(t, "SumTopK(6)", func() { s.SumTopK(6) })
+	expectPanic(t, "SumBottomK(-1)", func() { s.SumBottomK(-1) })
+	s.CreateBins(3, 0, 1)
+	expectPanic(t, "SumTopK after CreateBins", func() { s.SumTopK(1) })
+}
+
+func TestModalInterval(t *testing.T) {
+	s := NewStats()
+	s.CreateBins(7, 0, 10)
+	if low, high, count := s.ModalInterval(); low != 0 || high != 0 || count != 0 {
+		t.Errorf("empty ModalInterval() = (%v, %v, %d), expected (0, 0, 0)", low, high, count)
+	}
+	insertSamples(s, []Sample{1, 3, 4.1, 4.5, 5, 5.5, 5.9, 7, 9, -3})
+	if low, high, count := s.ModalInterval(); low != 4 || high != 6 || count != 5 {
+		t.Errorf("ModalInterval() = (%v, %v, %d), expected (4, 6, 5)", low, high, count)
+	}
+	// ties go to the lowest bin
+	s = NewStats()
+	s.CreateBins(4, 0, 2)
+	insertSamples(s, []Sample{1.5, 0.5, 1.5, 0.5})
+	if low, high, count := s.ModalInterval(); low != 0 || high != 1 || count != 2 {
+		t.Errorf("tied ModalInterval() = (%v, %v, %d), expected (0, 1, 2)", low, high, count)
+	}
+	expectPanic(t, "ModalInterval before CreateBins", func() { NewStats().ModalInterval() })
+}
@@ -0,0 +1,188 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"math"
+	"sort"
+)
+
+// A WeightedStats represents statistics about samples which each carry a
+// weight, such as a measurement's reliability or a count of identical
+// observations.
+//
+// The mean and central moments are updated incrementally, generalizing
+// Welford's algorithm to weights and to the third and fourth moments, rather
+// than derived from raw sums of powers of the samples, which cancel
+// catastrophically when the samples are large relative to their spread.
+type WeightedStats struct {
+	count int
+	// sums of the weights and the squared weights
+	sumW, sumW2 float64
+	mean        float64
+	// m2, m3 and m4 are the weighted sums Σw(x-mean)^k
+	m2, m3, m4 float64
+}
+
+// NewWeightedStats returns a new WeightedStats
+func NewWeightedStats() *WeightedStats {
+	return &WeightedStats{}
+}
+
+// AddWeightedSample adds val with the given weight, which must not be
+// negative.
+func (s *WeightedStats) AddWeightedSample(val Sample, weight float64) {
+	if weight < 0 {
+		panic("negative weight")
+	}
+	s.count++
+	if weight == 0 {
+		return
+	}
+	// merge the single weighted sample into the accumulated moments, using
+	// the pairwise update formulas with the old weight a and new weight b
+	a, b := s.sumW, weight
+	n := a + b
+	d := float64(val) - s.mean
+	db := d * b / n
+	s.m4 += d*d*d*db*a*(a*a-a*b+b*b)/(n*n) + 6*db*db*s.m2 - 4*db*s.m3
+	s.m3 += d*d*db*a*(a-b)/n - 3*db*s.m2
+	s.m2 += d * db * a
+	s.mean += db
+	s.sumW = n
+	s.sumW2 += b * b
+}
+
+// Count returns the number of samples added, regardless of their weights.
+func (s WeightedStats) Count() int {
+	return s.count
+}
+
+// TotalWeight returns the sum of the weights of the samples added.
+func (s WeightedStats) TotalWeight() float64 {
+	return s.sumW
+}
+
+// WeightedMean returns the weighted mean of the samples, Σwx/Σw. It is NaN
+// if the total weight is 0.
+func (s WeightedStats) WeightedMean() float64 {
+	if s.sumW == 0 {
+		return math.NaN()
+	}
+	return s.mean
+}
+
+// WeightedVariance returns the unbiased weighted variance for reliability
+// weights, Σw(x-μ)² / (V1 - V2/V1) where V1 = Σw and V2 = Σw². This treats the
+// weights as measures of each sample's importance, and the result does not
+// change if all the weights are scaled by a constant.
+//
+// It is not the unbiased variance for frequency weights, where each weight is
+// a count of identical observations; that is Σw(x-μ)² / (V1 - 1), the same as
+// the unbiased variance of the expanded data. The two agree only when all the
+// weights are 1. Multiplying the result by (V1 - V2/V1)/V1 gives the biased
+// variance Σw(x-μ)²/V1, which is the population variance of the expanded data
+// under either convention.
+//
+// It is NaN if fewer than two samples have non-zero weight.
+func (s WeightedStats) WeightedVariance() float64 {
+	return s.m2 / (s.sumW - s.sumW2/s.sumW)
+}
+
+// WeightedStddev returns the square root of WeightedVariance.
+func (s WeightedStats) WeightedStddev() float64 {
+	return math.Sqrt(s.WeightedVariance())
+}
+
+// weightedSamples sorts values together with their weights.
+type weightedSamples struct {
+	values  []Sample
+	weights []float64
+}
+
+func (w weightedSamples) Len() int {
+	return len(w.values)
+}
+
+func (w weightedSamples) Less(i, j int) bool {
+	return w.values[i] < w.values[j]
+}
+
+func (w weightedSamples) Swap(i, j int) {
+	w.values[i], w.values[j] = w.values[j], w.values[i]
+	w.weights[i], w.weights[j] = w.weights[j], w.weights[i]
+}
+
+// WeightedMedian returns the weighted median of values, where values[i] has
+// weight weights[i]: the value at which the cumulative weight of the values
+// in ascending order first exceeds half the total weight. If the cumulative
+// weight is exactly half the total after some value, the median is the
+// midpoint of that value and the next one with non-zero weight, as for the
+// unweighted median of an even number of values.
+//
+// The weights must not be negative. It returns 0 if the total weight is 0.
+// Neither slice is modified.
+func WeightedMedian(values []Sample, weights []float64) Sample {
+	if len(values) != len(weights) {
+		panic("values and weights differ in length")
+	}
+	w := weightedSamples{
+		values:  append([]Sample(nil), values...),
+		weights: append([]float64(nil), weights...),
+	}
+	var total float64
+	for _, weight := range w.weights {
+		if weight < 0 {
+			panic("negative weight")
+		}
+		total += weight
+	}
+	if total == 0 {
+		return 0
+	}
+	sort.Sort(w)
+	half := total / 2
+	var cum float64
+	for i, weight := range w.weights {
+		cum += weight
+		if cum > half {
+			return w.values[i]
+		}
+		if cum == half && weight > 0 {
+			for j := i + 1; j < len(w.values); j++ {
+				if w.weights[j] > 0 {
+					return (w.values[i] + w.values[j]) / 2
+				}
+			}
+			return w.values[i]
+		}
+	}
+	// only reachable through rounding error in cum
+	return w.values[len(w.values)-1]
+}
+
+// centralMoments returns the second, third and fourth weighted central
+// moments, Σw(x-μ)^k/Σw.
+func (s WeightedStats) centralMoments() (m2, m3, m4 float64) {
+	return s.m2 / s.sumW, s.m3 / s.sumW, s.m4 / s.sumW
+}
+
+// WeightedSkewness returns the weighted skewness of the samples, m3/m2^(3/2)
+// where mk = Σw(x-μ)^k/Σw is the k'th weighted central moment. The moments
+// are the population (biased) ones, with no small-sample correction, so with
+// integer weights the result equals the skewness of the data expanded by
+// repeating each sample weight times. It is NaN if the samples do not vary.
+func (s WeightedStats) WeightedSkewness() float64 {
+	m2, m3, _ := s.centralMoments()
+	return m3 / math.Pow(m2, 1.5)
+}
+
+// WeightedKurtosis returns the weighted excess kurtosis of the samples,
+// m4/m2² - 3, with the weighted central moments as for WeightedSkewness. It
+// is 0 for normally distributed data.
+func (s WeightedStats) WeightedKurtosis() float64 {
+	m2, _, m4 := s.centralMoments()
+	return m4/(m2*m2) - 3
+}
@@ -0,0 +1,145 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestWeightedMedian(t *testing.T) {
+	for i, test := range []struct {
+		values  []Sample
+		weights []float64
+		median  Sample
+	}{
+		{[]Sample{1, 2, 3}, []float64{1, 1, 10}, 3},
+		{[]Sample{3, 1, 2}, []float64{10, 1, 1}, 3},
+		{[]Sample{1, 2, 3}, []float64{1, 1, 1}, 2},
+		{[]Sample{1, 2, 3, 4}, []float64{1, 1, 1, 1}, 2.5},
+		{[]Sample{1, 2, 3, 4}, []float64{1, 1, 0, 2}, 3},
+		{[]Sample{5}, []float64{2}, 5},
+		{[]Sample{}, []float64{}, 0},
+	} {
+		if got := WeightedMedian(test.values, test.weights); got != test.median {
+			t.Errorf("[%d] WeightedMedian(%v, %v) = %v, expected %v", i, test.values, test.weights, got, test.median)
+		}
+	}
+	expectPanic(t, "WeightedMedian with mismatched lengths", func() {
+		WeightedMedian([]Sample{1, 2}, []float64{1})
+	})
+	expectPanic(t, "WeightedMedian with a negative weight", func() {
+		WeightedMedian([]Sample{1, 2}, []float64{1, -1})
+	})
+}
+
+func TestWeightedVariance(t *testing.T) {
+	values := []Sample{2, 4, 7, 10}
+	weights := []float64{3, 1, 2, 2}
+	s := NewWeightedStats()
+	expanded := NewStats()
+	for i, val := range values {
+		s.AddWeightedSample(val, weights[i])
+		for j := 0; j < int(weights[i]); j++ {
+			expanded.AddSample(val)
+		}
+	}
+	if s.Count() != 4 || s.TotalWeight() != 8 {
+		t.Errorf("Count(), TotalWeight() = %d, %v, expected 4, 8", s.Count(), s.TotalWeight())
+	}
+	if got := s.WeightedMean(); math.Abs(got-expanded.Mean()) > 1e-12 {
+		t.Errorf("WeightedMean() = %v, expected %v", got, expanded.Mean())
+	}
+	// V1 = 8, V2 = 18, so the reliability denominator is 8 - 18/8 = 5.75
+	biased := s.WeightedVariance() * 5.75 / 8
+	if math.Abs(biased-expanded.Variance()) > 1e-12 {
+		t.Errorf("biased WeightedVariance() = %v, expected %v", biased, expanded.Variance())
+	}
+	if got := s.WeightedStddev(); math.Abs(got*got-s.WeightedVariance()) > 1e-12 {
+		t.Errorf("WeightedStddev() = %v, not the square root of %v", got, s.WeightedVariance())
+	}
+
+	// with unit weights reliability weights agree with the unbiased variance
+	s = NewWeightedStats()
+	unbiased := NewStats(WithUnbiasedVariance())
+	for _, val := range values {
+		s.AddWeightedSample(val, 1)
+		unbiased.AddSample(val)
+	}
+	if got := s.WeightedVariance(); math.Abs(got-unbiased.Variance()) > 1e-12 {
+		t.Errorf("unit WeightedVariance() = %v, expected %v", got, unbiased.Variance())
+	}
+	expectPanic(t, "AddWeightedSample with a negative weight", func() {
+		s.AddWeightedSample(1, -1)
+	})
+}
+
+func TestWeightedSkewnessKurtosis(t *testing.T) {
+	values := []Sample{1, 2, 3, 10}
+	weights := []float64{4, 2, 1, 1}
+	s := NewWeightedStats()
+	expanded := NewStats()
+	for i, val := range values {
+		s.AddWeightedSample(val, weights[i])
+		for j := 0; j < int(weights[i]); j++ {
+			expanded.AddSample(val)
+		}
+	}
+	m2 := expanded.CentralMoment(2)
+	skew := expanded.CentralMoment(3) / math.Pow(m2, 1.5)
+	kurt := expanded.CentralMoment(4)/(m2*m2) - 3
+	if got := s.WeightedSkewness(); math.Abs(got-skew) > 1e-9 {
+		t.Errorf("WeightedSkewness() = %v, expected %v", got, skew)
+	}
+	if got := s.WeightedKurtosis(); math.Abs(got-kurt) > 1e-9 {
+		t.Errorf("WeightedKurtosis() = %v, expected %v", got, kurt)
+	}
+	if skew <= 0 {
+		t.Errorf("right-skewed data has skewness %v", skew)
+	}
+
+	// symmetric data has no skew, and two equal spikes have kurtosis 1-3
+	s = NewWeightedStats()
+	s.AddWeightedSample(-1, 0.5)
+	s.AddWeightedSample(1, 0.5)
+	if got := s.WeightedSkewness(); got != 0 {
+		t.Errorf("symmetric WeightedSkewness() = %v, expected 0", got)
+	}
+	if got := s.WeightedKurtosis(); math.Abs(got+2) > 1e-12 {
+		t.Errorf("two-point WeightedKurtosis() = %v, expected -2", got)
+	}
+}
+
+func TestWeightedMomentsOffset(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, offset := range []float64{0, 1e4, 1e5, 1e8} {
+		s := NewWeightedStats()
+		base := NewWeightedStats()
+		for i := 0; i < 10000; i++ {
+			x, w := r.NormFloat64(), 1+r.Float64()
+			s.AddWeightedSample(Sample(offset+x), w)
+			base.AddWeightedSample(Sample(x), w)
+		}
+		if got := s.WeightedMean() - offset; math.Abs(got-base.WeightedMean()) > 1e-6 {
+			t.Errorf("offset %g: WeightedMean() - offset = %v, expected %v", offset, got, base.WeightedMean())
+		}
+		for _, m := range []struct {
+			name      string
+			got, base float64
+		}{
+			{"WeightedVariance", s.WeightedVariance(), base.WeightedVariance()},
+			{"WeightedSkewness", s.WeightedSkewness(), base.WeightedSkewness()},
+			{"WeightedKurtosis", s.WeightedKurtosis(), base.WeightedKurtosis()},
+		} {
+			if math.Abs(m.got-m.base) > 1e-6 {
+				t.Errorf("offset %g: %s() = %v, expected %v", offset, m.name, m.got, m.base)
+			}
+		}
+		if k := s.WeightedKurtosis(); math.Abs(k) > 0.2 {
+			t.Errorf("offset %g: WeightedKurtosis() of normal data = %v, expected about 0", offset, k)
+		}
+	}
+}
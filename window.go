@@ -0,0 +1,211 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import "math"
+
+// A WindowStats represents descriptive statistics about the most recent
+// Samples added, up to a fixed window size.
+//
+// The mean and variance are maintained with Welford's algorithm rather than
+// from running sums of the samples and their squares, which lose precision
+// through cancellation over long runs.
+type WindowStats struct {
+	window []Sample
+	next   int
+	count  int
+	mean   float64
+	m2     float64
+}
+
+// NewWindowStats returns a new WindowStats over the last size samples.
+func NewWindowStats(size int) *WindowStats {
+	if size < 1 {
+		panic("window size must be positive")
+	}
+	return &WindowStats{
+		window: make([]Sample, size),
+	}
+}
+
+// AddSample adds a sample value, evicting the oldest sample if the window is
+// full, and updates the statistics.
+func (w *WindowStats) AddSample(val Sample) {
+	if w.count == len(w.window) {
+		w.remove(w.window[w.next])
+	}
+	w.window[w.next] = val
+	w.next = (w.next + 1) % len(w.window)
+	w.add(val)
+}
+
+// add is the forward Welford update.
+func (w *WindowStats) add(val Sample) {
+	x := float64(val)
+	w.count++
+	delta := x - w.mean
+	w.mean += delta / float64(w.count)
+	w.m2 += delta * (x - w.mean)
+}
+
+// remove is the reverse Welford update, undoing add for a value which is
+// leaving the window.
+//
+// The subtraction from m2 can leave a tiny negative residue through rounding
+// when the remaining values are (nearly) equal, so it is clamped at zero.
+func (w *WindowStats) remove(val Sample) {
+	x := float64(val)
+	if w.count == 1 {
+		w.count, w.mean, w.m2 = 0, 0, 0
+		return
+	}
+	oldMean := w.mean
+	w.count--
+	w.mean -= (x - w.mean) / float64(w.count)
+	w.m2 -= (x - oldMean) * (x - w.mean)
+	if w.m2 < 0 {
+		w.m2 = 0
+	}
+}
+
+// Count returns the number of samples in the window.
+func (w WindowStats) Count() int {
+	return w.count
+}
+
+// Mean returns the mean of the samples in the window.
+func (w WindowStats) Mean() float64 {
+	if w.count == 0 {
+		return math.NaN()
+	}
+	return w.mean
+}
+
+// Stddev returns the standard deviation of the samples in the window.
+func (w WindowStats) Stddev() float64 {
+	if w.count == 0 {
+		return math.NaN()
+	}
+	return math.Sqrt(w.m2 / float64(w.count))
+}
+
+// A WindowFunc selects the weights WeightedWindowStats gives the samples
+// across its window.
+type WindowFunc int
+
+const (
+	// Rectangular weights every sample in the window equally, as WindowStats
+	// does.
+	Rectangular WindowFunc = iota
+	// Triangular weights samples in proportion to their distance from the
+	// nearer end of the window.
+	Triangular
+	// Welch weights samples by a parabola, 1-x², peaking in the middle of the
+	// window.
+	Welch
+)
+
+// A WeightedWindowStats represents the weighted mean and variance of the most
+// recent Samples added, up to a fixed window size, with the samples weighted
+// by a window function. Tapering the weights towards the ends of the window
+// means a sample's influence grows and fades gradually as it passes through
+// the window, so a step in the input produces a smooth S-shaped transition in
+// the mean rather than the straight ramp, with abrupt corners, of a
+// rectangular window.
+//
+// The weights are computed over the samples currently held: with m samples,
+// the k'th oldest (counting from 0) is at x = (2k+1)/m - 1 in (-1, 1), and its
+// weight is 1 for Rectangular, 1-|x| for Triangular and 1-x² for Welch. The
+// statistics are recomputed from the window on each call, taking time
+// proportional to its size.
+type WeightedWindowStats struct {
+	window []Sample
+	next   int
+	count  int
+	fn     WindowFunc
+}
+
+// NewWeightedWindowStats returns a new WeightedWindowStats over the last size
+// samples, weighted by fn.
+func NewWeightedWindowStats(size int, fn WindowFunc) *WeightedWindowStats {
+	if size < 1 {
+		panic("window size must be positive")
+	}
+	if fn < Rectangular || fn > Welch {
+		panic("unknown window function")
+	}
+	return &WeightedWindowStats{
+		window: make([]Sample, size),
+		fn:     fn,
+	}
+}
+
+// AddSample adds a sample value, evicting the oldest sample if the window is
+// full.
+func (w *WeightedWindowStats) AddSample(val Sample) {
+	w.window[w.next] = val
+	w.next = (w.next + 1) % len(w.window)
+	if w.count < len(w.window) {
+		w.count++
+	}
+}
+
+// Count returns the number of samples in the window.
+func (w WeightedWindowStats) Count() int {
+	return w.count
+}
+
+// weight returns the weight of the k'th oldest sample in the window.
+func (w WeightedWindowStats) weight(k int) float64 {
+	x := float64(2*k+1)/float64(w.count) - 1
+	switch w.fn {
+	case Triangular:
+		return 1 - math.Abs(x)
+	case Welch:
+		return 1 - x*x
+	}
+	return 1
+}
+
+// sample returns the k'th oldest sample in the window.
+func (w WeightedWindowStats) sample(k int) float64 {
+	return float64(w.window[(w.next-w.count+k+len(w.window))%len(w.window)])
+}
+
+// Mean returns the weighted mean of the samples in the window.
+func (w WeightedWindowStats) Mean() float64 {
+	if w.count == 0 {
+		return math.NaN()
+	}
+	var sumW, sumWX float64
+	for k := 0; k < w.count; k++ {
+		weight := w.weight(k)
+		sumW += weight
+		sumWX += weight * w.sample(k)
+	}
+	return sumWX / sumW
+}
+
+// Variance returns the weighted variance of the samples in the window,
+// Σw(x-mean)²/Σw.
+func (w WeightedWindowStats) Variance() float64 {
+	if w.count == 0 {
+		return math.NaN()
+	}
+	mean := w.Mean()
+	var sumW, sumSq float64
+	for k := 0; k < w.count; k++ {
+		weight := w.weight(k)
+		d := w.sample(k) - mean
+		sumW += weight
+		sumSq += weight * d * d
+	}
+	return sumSq / sumW
+}
+
+// Stddev returns the square root of Variance.
+func (w WeightedWindowStats) Stddev() float64 {
+	return math.Sqrt(w.Variance())
+}
@@ -0,0 +1,105 @@
+// Copyright 2012 The Summstat Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package summstat
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestWindowStats(t *testing.T) {
+	const size = 50
+	// large offsets make the naive sum2 formulation lose precision
+	for _, offset := range []float64{1e6, 1e9} {
+		// the samples themselves are only held to about 1e-16 of the offset
+		tol := 1e-14 * offset
+		r := rand.New(rand.NewSource(1))
+		w := NewWindowStats(size)
+		var all []Sample
+		for i := 0; i < 10000; i++ {
+			val := Sample(offset + r.NormFloat64())
+			all = append(all, val)
+			w.AddSample(val)
+
+			start := len(all) - size
+			if start < 0 {
+				start = 0
+			}
+			s := NewStats()
+			insertSamples(s, all[start:])
+			if w.Count() != s.Count() {
+				t.Fatalf("[%v %d] Count() = %d, expected %d", offset, i, w.Count(), s.Count())
+			}
+			// the reference mean is taken about the offset, as Stats.Mean
+			// itself loses precision at 1e9
+			var mean, m2 float64
+			for _, x := range all[start:] {
+				mean += float64(x) - offset
+			}
+			mean = offset + mean/float64(s.Count())
+			for _, x := range all[start:] {
+				d := float64(x) - mean
+				m2 += d * d
+			}
+			stddev := math.Sqrt(m2 / float64(s.Count()))
+			if math.Abs(w.Mean()-mean) > tol {
+				t.Fatalf("[%v %d] Mean() = %v, expected %v", offset, i, w.Mean(), mean)
+			}
+			if math.Abs(w.Stddev()-stddev) > tol {
+				t.Fatalf("[%v %d] Stddev() = %v, expected %v", offset, i, w.Stddev(), stddev)
+			}
+		}
+	}
+}
+
+func TestWeightedWindowStats(t *testing.T) {
+	const size = 10
+	rect := NewWeightedWindowStats(size, Rectangular)
+	tri := NewWeightedWindowStats(size, Triangular)
+	plain := NewWindowStats(size)
+	var rectMeans, triMeans []float64
+	for i := 0; i < 3*size; i++ {
+		val := Sample(0)
+		if i >= size {
+			val = 1
+		}
+		rect.AddSample(val)
+		tri.AddSample(val)
+		plain.AddSample(val)
+		if math.Abs(rect.Mean()-plain.Mean()) > 1e-6 || math.Abs(rect.Stddev()-plain.Stddev()) > 1e-6 {
+			t.Fatalf("[%d] rectangular (%v, %v), expected (%v, %v) as WindowStats", i, rect.Mean(), rect.Stddev(), plain.Mean(), plain.Stddev())
+		}
+		rectMeans = append(rectMeans, rect.Mean())
+		triMeans = append(triMeans, tri.Mean())
+	}
+	if rect.Count() != size || tri.Mean() != 1 || tri.Variance() != 0 {
+		t.Errorf("after the step Count() = %d, Mean() = %v, Variance() = %v, expected %d, 1, 0", rect.Count(), tri.Mean(), tri.Variance(), size)
+	}
+	// the rectangular mean ramps with sharp corners, the triangular one curves
+	maxBend := func(means []float64) float64 {
+		bend := 0.0
+		for i := 2; i < len(means); i++ {
+			bend = math.Max(bend, math.Abs(means[i]-2*means[i-1]+means[i-2]))
+		}
+		return bend
+	}
+	if rb, tb := maxBend(rectMeans), maxBend(triMeans); tb >= rb/2 {
+		t.Errorf("triangular mean bends by up to %v, expected well under the rectangular %v", tb, rb)
+	}
+
+	welch := NewWeightedWindowStats(3, Welch)
+	vals := []Sample{3, 6, 9}
+	for _, val := range vals {
+		welch.AddSample(val)
+	}
+	// weights 5/9, 1, 5/9
+	if got := welch.Mean(); math.Abs(got-6) > 1e-12 {
+		t.Errorf("Welch Mean() = %v, expected 6", got)
+	}
+	if got := welch.Variance(); math.Abs(got-90.0/19) > 1e-12 {
+		t.Errorf("Welch Variance() = %v, expected %v", got, 90.0/19)
+	}
+}